@@ -0,0 +1,250 @@
+// Package chart renders small svg charts that work without javascript
+package chart
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"strings"
+)
+
+// chart size and padding in svg user units
+const (
+	width      = 600
+	height     = 200
+	padLeft    = 52
+	padRight   = 12
+	padTop     = 12
+	padBottom  = 28
+	plotWidth  = width - padLeft - padRight
+	plotHeight = height - padTop - padBottom
+)
+
+// Default colors of the charts
+const (
+	ColorLine = "#e0af68"
+	ColorAxis = "#999"
+	ColorGrid = "#333"
+)
+
+// Point is a data point of a line chart
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Line is a chart of a single series of points sorted by X
+type Line struct {
+	Title  string
+	Points []Point
+	// Fill draws the area below the line
+	Fill bool
+	// InvertY draws lower values on top, e.g. for pace
+	InvertY bool
+	Color   string
+	// YStep is the distance between Y axis ticks, chosen automatically when 0
+	YStep float64
+	// XLabel and YLabel format the axis tick values
+	XLabel func(float64) string
+	YLabel func(float64) string
+}
+
+func defaultLabel(v float64) string {
+	return fmt.Sprintf("%g", v)
+}
+
+// niceStep returns a step of 1, 2 or 5 times a power of ten that splits
+// the span in about n ticks
+func niceStep(span float64, n int) float64 {
+	if span <= 0 || n <= 0 {
+		return 1
+	}
+	raw := span / float64(n)
+	mag := math.Pow10(int(math.Floor(math.Log10(raw))))
+	switch r := raw / mag; {
+	case r <= 1:
+		return mag
+	case r <= 2:
+		return 2 * mag
+	case r <= 5:
+		return 5 * mag
+	default:
+		return 10 * mag
+	}
+}
+
+// ticks returns the multiples of step between min and max
+func ticks(min, max, step float64) []float64 {
+	var res []float64
+	for v := math.Ceil(min/step) * step; v <= max+step*1e-9; v += step {
+		res = append(res, v)
+	}
+	return res
+}
+
+func bounds(points []Point) (minX, maxX, minY, maxY float64) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	for _, p := range points {
+		minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+		minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+	}
+	if maxX == minX {
+		maxX = minX + 1
+	}
+	if maxY == minY {
+		minY, maxY = minY-1, maxY+1
+	}
+	return minX, maxX, minY, maxY
+}
+
+// SVG returns the chart as an svg element, or an empty string
+// when there are less than two points.
+func (l Line) SVG() template.HTML {
+	if len(l.Points) < 2 {
+		return ""
+	}
+	color, xLabel, yLabel := l.Color, l.XLabel, l.YLabel
+	if color == "" {
+		color = ColorLine
+	}
+	if xLabel == nil {
+		xLabel = defaultLabel
+	}
+	if yLabel == nil {
+		yLabel = defaultLabel
+	}
+
+	minX, maxX, minY, maxY := bounds(l.Points)
+	yStep := l.YStep
+	if yStep <= 0 {
+		yStep = niceStep(maxY-minY, 4)
+	}
+	minY = math.Floor(minY/yStep) * yStep
+	maxY = math.Ceil(maxY/yStep) * yStep
+
+	x := func(v float64) float64 {
+		return padLeft + (v-minX)/(maxX-minX)*plotWidth
+	}
+	y := func(v float64) float64 {
+		r := (v - minY) / (maxY - minY)
+		if l.InvertY {
+			r = 1 - r
+		}
+		return padTop + (1-r)*plotHeight
+	}
+
+	var b strings.Builder
+	writeOpen(&b, height, l.Title)
+	for _, v := range ticks(minY, maxY, yStep) {
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="%s"/>`, padLeft, y(v), width-padRight, y(v), ColorGrid)
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" fill="%s" font-size="11" text-anchor="end" dominant-baseline="middle">%s</text>`,
+			padLeft-6, y(v), ColorAxis, html.EscapeString(yLabel(v)))
+	}
+	for _, v := range ticks(minX, maxX, niceStep(maxX-minX, 6)) {
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" fill="%s" font-size="11" text-anchor="middle">%s</text>`,
+			x(v), height-8, ColorAxis, html.EscapeString(xLabel(v)))
+	}
+
+	var path strings.Builder
+	for i, p := range l.Points {
+		cmd := "L"
+		if i == 0 {
+			cmd = "M"
+		}
+		fmt.Fprintf(&path, "%s%.1f %.1f ", cmd, x(p.X), y(p.Y))
+	}
+	if l.Fill {
+		base := y(minY)
+		if l.InvertY {
+			base = y(maxY)
+		}
+		fmt.Fprintf(&b, `<path d="%sL%.1f %.1f L%.1f %.1f Z" fill="%s" fill-opacity="0.25"/>`,
+			path.String(), x(l.Points[len(l.Points)-1].X), base, x(l.Points[0].X), base, color)
+	}
+	fmt.Fprintf(&b, `<path d="%s" fill="none" stroke="%s" stroke-width="1.5" stroke-linejoin="round"/>`,
+		strings.TrimSpace(path.String()), color)
+	b.WriteString("</svg>")
+	return template.HTML(b.String())
+}
+
+// Bar is a labeled value of a bar chart
+type Bar struct {
+	Label string
+	Value float64
+	// Text is shown next to the bar instead of the value
+	Text  string
+	Color string
+}
+
+// Bars is a horizontal bar chart
+type Bars struct {
+	Title string
+	Bars  []Bar
+}
+
+// SVG returns the chart as an svg element, or an empty string
+// when all values are zero.
+func (c Bars) SVG() template.HTML {
+	var max float64
+	for _, bar := range c.Bars {
+		max = math.Max(max, bar.Value)
+	}
+	if max <= 0 {
+		return ""
+	}
+
+	const rowHeight, barHeight, textWidth = 28, 18, 80
+	h := len(c.Bars)*rowHeight + padTop
+	var b strings.Builder
+	writeOpen(&b, h, c.Title)
+	for i, bar := range c.Bars {
+		color, text := bar.Color, bar.Text
+		if color == "" {
+			color = ColorLine
+		}
+		if text == "" {
+			text = defaultLabel(bar.Value)
+		}
+		top := padTop/2 + i*rowHeight
+		w := bar.Value / max * (width - padLeft - padRight - textWidth)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" fill="%s" font-size="12" text-anchor="end" dominant-baseline="middle">%s</text>`,
+			padLeft-6, top+barHeight/2, ColorAxis, html.EscapeString(bar.Label))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="%d" fill="%s"/>`, padLeft, top, w, barHeight, color)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" fill="%s" font-size="12" dominant-baseline="middle">%s</text>`,
+			padLeft+w+6, top+barHeight/2, ColorAxis, html.EscapeString(text))
+	}
+	b.WriteString("</svg>")
+	return template.HTML(b.String())
+}
+
+// writeOpen writes the svg start tag and the accessible title of a chart
+func writeOpen(b *strings.Builder, h int, title string) {
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" role="img">`, width, h)
+	if title != "" {
+		fmt.Fprintf(b, "<title>%s</title>", html.EscapeString(title))
+	}
+}
+
+// Downsample averages the points in n buckets of equal size so that long
+// activities don't produce huge charts. Points are returned unchanged when
+// there are n or less.
+func Downsample(points []Point, n int) []Point {
+	if n <= 0 || len(points) <= n {
+		return points
+	}
+	res := make([]Point, 0, n)
+	for i := 0; i < n; i++ {
+		bucket := points[i*len(points)/n : (i+1)*len(points)/n]
+		var p Point
+		for _, bp := range bucket {
+			p.X += bp.X
+			p.Y += bp.Y
+		}
+		p.X /= float64(len(bucket))
+		p.Y /= float64(len(bucket))
+		res = append(res, p)
+	}
+	return res
+}
@@ -0,0 +1,104 @@
+package chart
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ddominguez/run-david-run/polyline"
+)
+
+func TestNiceStep(t *testing.T) {
+	testCases := []struct {
+		span     float64
+		n        int
+		expected float64
+	}{
+		{100, 4, 50},
+		{26.2, 6, 5},
+		{3.1, 6, 1},
+		{0.8, 4, 0.2},
+		{0, 4, 1},
+	}
+	for _, tc := range testCases {
+		if res := niceStep(tc.span, tc.n); res != tc.expected {
+			t.Errorf("niceStep(%g, %d). Found(%g), Expected(%g)", tc.span, tc.n, res, tc.expected)
+		}
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	points := []Point{{0, 0}, {1, 2}, {2, 4}, {3, 6}, {4, 8}, {5, 10}}
+	res := Downsample(points, 3)
+	expected := []Point{{0.5, 1}, {2.5, 5}, {4.5, 9}}
+	if len(res) != len(expected) {
+		t.Fatalf("Incorrect number of points. Found(%v), Expected(%v)", res, expected)
+	}
+	for i := range expected {
+		if res[i] != expected[i] {
+			t.Errorf("Incorrect point %d. Found(%v), Expected(%v)", i, res[i], expected[i])
+		}
+	}
+	if res := Downsample(points, 10); len(res) != len(points) {
+		t.Errorf("Expected points to be unchanged. Found(%v)", res)
+	}
+}
+
+func TestLineSVG(t *testing.T) {
+	if res := (Line{Points: []Point{{0, 1}}}).SVG(); res != "" {
+		t.Errorf("Expected no chart for a single point. Found(%s)", res)
+	}
+
+	res := string(Line{Title: "Elevation <m>", Points: []Point{{0, 10}, {1, 30}, {2, 20}}, Fill: true}.SVG())
+	if !strings.HasPrefix(res, "<svg") || !strings.HasSuffix(res, "</svg>") {
+		t.Errorf("Expected an svg element. Found(%s)", res)
+	}
+	if !strings.Contains(res, "<title>Elevation &lt;m&gt;</title>") {
+		t.Errorf("Expected an escaped title. Found(%s)", res)
+	}
+	if n := strings.Count(res, "<path"); n != 2 {
+		t.Errorf("Incorrect number of paths for a filled line. Found(%d), Expected(%d)", n, 2)
+	}
+}
+
+func TestBarsSVG(t *testing.T) {
+	if res := (Bars{Bars: []Bar{{Label: "Z1"}}}).SVG(); res != "" {
+		t.Errorf("Expected no chart for zero values. Found(%s)", res)
+	}
+	res := string(Bars{Bars: []Bar{{Label: "Z1", Value: 10}, {Label: "Z2", Value: 20, Text: "0:20"}}}.SVG())
+	if n := strings.Count(res, "<rect"); n != 2 {
+		t.Errorf("Incorrect number of bars. Found(%d), Expected(%d)", n, 2)
+	}
+	if !strings.Contains(res, ">0:20<") {
+		t.Errorf("Expected the bar text. Found(%s)", res)
+	}
+}
+
+func TestRouteSVG(t *testing.T) {
+	if res := (Route{Points: []polyline.Point{{Lat: 40, Lng: -74}}}).SVG(); res != "" {
+		t.Errorf("Expected no map for a single point. Found(%s)", res)
+	}
+
+	// about 3.3 km north
+	r := Route{Points: []polyline.Point{{Lat: 40.70, Lng: -74.0}, {Lat: 40.73, Lng: -74.0}}, MarkerEvery: 1000}
+	res := string(r.SVG())
+	if n := strings.Count(res, "<circle"); n != 5 {
+		t.Errorf("Incorrect number of markers. Found(%d), Expected(%d)", n, 5)
+	}
+	if !strings.Contains(res, "<title>Start</title>") || !strings.Contains(res, "<title>Finish</title>") {
+		t.Errorf("Expected start and finish markers. Found(%s)", res)
+	}
+}
+
+func TestRouteMarkerOffset(t *testing.T) {
+	// about 3.3 km north, starting 1.5 km into the course
+	r := Route{Points: []polyline.Point{{Lat: 40.70, Lng: -74.0}, {Lat: 40.73, Lng: -74.0}}, MarkerEvery: 1000, MarkerOffset: 1500}
+	res := string(r.SVG())
+	for _, n := range []string{">2<", ">3<", ">4<"} {
+		if !strings.Contains(res, n) {
+			t.Errorf("Expected marker %s. Found(%s)", n, res)
+		}
+	}
+	if strings.Contains(res, ">1<") || strings.Contains(res, ">5<") {
+		t.Errorf("Unexpected marker outside the route. Found(%s)", res)
+	}
+}
@@ -0,0 +1,137 @@
+package chart
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"strings"
+
+	"github.com/ddominguez/run-david-run/polyline"
+)
+
+// route map size and padding in svg user units
+const (
+	routeWidth   = 600
+	routeHeight  = 400
+	routePadding = 24
+)
+
+// Colors of the route map
+const (
+	ColorRoute      = "#f7768e"
+	ColorStart      = "#9ece6a"
+	ColorFinish     = "#eee"
+	ColorBackground = "#1a1b26"
+)
+
+// Route is a map of the course of an activity
+type Route struct {
+	Title  string
+	Points []polyline.Point
+	// MarkerEvery is the distance in meters between numbered
+	// markers along the course, no markers are drawn when 0.
+	MarkerEvery float64
+	// MarkerOffset is the distance in meters along the course of the first
+	// point, e.g. when the start of the route is hidden
+	MarkerOffset float64
+}
+
+// mercator projects a point to the web mercator plane
+func mercator(p polyline.Point) (float64, float64) {
+	lat := math.Max(-85, math.Min(85, p.Lat))
+	x := p.Lng * math.Pi / 180
+	y := math.Log(math.Tan(math.Pi/4 + lat*math.Pi/360))
+	return x, y
+}
+
+// marker is a numbered position along the course
+type marker struct {
+	polyline.Point
+	n int
+}
+
+// markers returns the positions found every `every` meters along the
+// points, starting offset meters into the course.
+func markers(points []polyline.Point, every, offset float64) []marker {
+	var res []marker
+	if every <= 0 {
+		return res
+	}
+	total := offset
+	n := int(math.Floor(offset/every)) + 1
+	next := float64(n) * every
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		d := polyline.Distance(a, b)
+		for d > 0 && total+d >= next {
+			r := (next - total) / d
+			res = append(res, marker{polyline.Point{
+				Lat: a.Lat + (b.Lat-a.Lat)*r,
+				Lng: a.Lng + (b.Lng-a.Lng)*r,
+			}, n})
+			n++
+			next += every
+		}
+		total += d
+	}
+	return res
+}
+
+// SVG returns the route as an svg element scaled to fit the map, or an
+// empty string when there are less than two points.
+func (r Route) SVG() template.HTML {
+	if len(r.Points) < 2 {
+		return ""
+	}
+
+	xs := make([]float64, len(r.Points))
+	ys := make([]float64, len(r.Points))
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for i, p := range r.Points {
+		xs[i], ys[i] = mercator(p)
+		minX, maxX = math.Min(minX, xs[i]), math.Max(maxX, xs[i])
+		minY, maxY = math.Min(minY, ys[i]), math.Max(maxY, ys[i])
+	}
+
+	// keep the aspect ratio and center the route in the map
+	scale := math.Min(
+		(routeWidth-2*routePadding)/math.Max(maxX-minX, 1e-9),
+		(routeHeight-2*routePadding)/math.Max(maxY-minY, 1e-9),
+	)
+	offsetX := (routeWidth - (maxX-minX)*scale) / 2
+	offsetY := (routeHeight - (maxY-minY)*scale) / 2
+	project := func(x, y float64) (float64, float64) {
+		return offsetX + (x-minX)*scale, routeHeight - offsetY - (y-minY)*scale
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" role="img">`, routeWidth, routeHeight)
+	if r.Title != "" {
+		fmt.Fprintf(&b, "<title>%s</title>", html.EscapeString(r.Title))
+	}
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" rx="6" fill="%s"/>`, routeWidth, routeHeight, ColorBackground)
+
+	b.WriteString(`<polyline points="`)
+	for i := range xs {
+		x, y := project(xs[i], ys[i])
+		fmt.Fprintf(&b, "%.1f,%.1f ", x, y)
+	}
+	fmt.Fprintf(&b, `" fill="none" stroke="%s" stroke-width="3" stroke-linejoin="round" stroke-linecap="round"/>`, ColorRoute)
+
+	for _, m := range markers(r.Points, r.MarkerEvery, r.MarkerOffset) {
+		x, y := project(mercator(m.Point))
+		fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="8" fill="%s" stroke="%s"/>`, x, y, ColorBackground, ColorRoute)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" fill="%s" font-size="9" text-anchor="middle" dominant-baseline="central">%d</text>`,
+			x, y, ColorFinish, m.n)
+	}
+
+	startX, startY := project(xs[0], ys[0])
+	finishX, finishY := project(xs[len(xs)-1], ys[len(ys)-1])
+	fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="6" fill="%s"><title>Start</title></circle>`, startX, startY, ColorStart)
+	fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="6" fill="%s" stroke="%s" stroke-width="2"><title>Finish</title></circle>`,
+		finishX, finishY, ColorFinish, ColorBackground)
+	b.WriteString("</svg>")
+	return template.HTML(b.String())
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ddominguez/run-david-run/db"
+	"github.com/ddominguez/run-david-run/page"
+)
+
+// athleteName returns the name of an athlete, or the strava id when the
+// athlete has no name
+func athleteName(a db.StravaAthlete) string {
+	if name := a.Name(); name != "" {
+		return name
+	}
+	return fmt.Sprintf("athlete %d", a.StravaId)
+}
+
+// matchAthlete returns the athlete with a strava id, slug or first name
+func matchAthlete(athletes []db.StravaAthlete, selector string) (db.StravaAthlete, error) {
+	var found []db.StravaAthlete
+	for _, a := range athletes {
+		if strconv.FormatUint(a.StravaId, 10) == selector || a.Slug() == strings.ToLower(selector) ||
+			(a.FirstName != "" && strings.EqualFold(a.FirstName, selector)) {
+			found = append(found, a)
+		}
+	}
+
+	switch len(found) {
+	case 1:
+		return found[0], nil
+	case 0:
+		names := make([]string, len(athletes))
+		for i, a := range athletes {
+			names[i] = a.Slug()
+		}
+		if len(names) == 0 {
+			return db.StravaAthlete{}, fmt.Errorf("athlete %s not found, there are no athletes", selector)
+		}
+		return db.StravaAthlete{}, fmt.Errorf("athlete %s not found, expected one of %s", selector, strings.Join(names, ", "))
+	default:
+		ids := make([]string, len(found))
+		for i, a := range found {
+			ids[i] = strconv.FormatUint(a.StravaId, 10)
+		}
+		return db.StravaAthlete{}, fmt.Errorf("athlete %s matches athletes %s, use the strava id", selector, strings.Join(ids, ", "))
+	}
+}
+
+// selectAthletes returns the athlete chosen with --athlete, or every athlete
+func selectAthletes(ctx context.Context) ([]db.StravaAthlete, error) {
+	athletes, err := store.AllStravaAthletesContext(ctx)
+	if err != nil || rootFlags.athlete == "" {
+		return athletes, err
+	}
+	a, err := matchAthlete(athletes, rootFlags.athlete)
+	if err != nil {
+		return nil, err
+	}
+	return []db.StravaAthlete{a}, nil
+}
+
+// athleteSite is the site of the races of an athlete. The site of a
+// single athlete is at the root, with several athletes every site is
+// under /athlete/<slug>/ and the root is the club page.
+type athleteSite struct {
+	athlete db.StravaAthlete
+	slug    string
+	// base is the path of the site, it always ends with a slash
+	base string
+	club bool
+	// clubUrl links the site to the club page, empty when there is none
+	clubUrl     string
+	isGenerated bool
+}
+
+// newAthleteSites returns the site of every athlete. athletes must be
+// every athlete of the store, their number decides the layout.
+func newAthleteSites(athletes []db.StravaAthlete, isGenerated bool) []athleteSite {
+	club := len(athletes) > 1
+	seen := map[string]bool{}
+	res := make([]athleteSite, len(athletes))
+	for i, a := range athletes {
+		site := athleteSite{athlete: a, slug: a.Slug(), base: "/", club: club, isGenerated: isGenerated}
+		// athletes with the same name are told apart by their strava id
+		if seen[site.slug] {
+			site.slug = fmt.Sprintf("%s-%d", site.slug, a.StravaId)
+		}
+		seen[site.slug] = true
+		if club {
+			site.base = fmt.Sprintf("/athlete/%s/", site.slug)
+			site.clubUrl = "/"
+		}
+		res[i] = site
+	}
+	return res
+}
+
+// page returns the site of the pages of the athlete
+func (s athleteSite) page() page.Site {
+	res := page.Site{Title: "Races", Name: s.athlete.FirstName, Url: s.base}
+	if s.athlete.FirstName != "" {
+		res.Title = fmt.Sprintf("Run, %s, Run!", s.athlete.FirstName)
+	}
+	if s.clubUrl != "" {
+		res.ClubUrl = s.clubUrl
+		res.ClubName = conf.Club
+	}
+	return res
+}
+
+// selectSites returns the sites of the athlete chosen with --athlete, or of
+// every athlete, and the sites of every athlete. The layout is decided by
+// every athlete, so the site of one athlete stays where it is in the club.
+func selectSites(ctx context.Context, isGenerated bool) ([]athleteSite, []athleteSite, error) {
+	athletes, err := store.AllStravaAthletesContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	all := newAthleteSites(athletes, isGenerated)
+	if rootFlags.athlete == "" {
+		return all, all, nil
+	}
+	a, err := matchAthlete(athletes, rootFlags.athlete)
+	if err != nil {
+		return nil, nil, err
+	}
+	i := slices.IndexFunc(all, func(s athleteSite) bool { return s.athlete.StravaId == a.StravaId })
+	return all[i : i+1], all, nil
+}
+
+// clubSite returns the site of the club page
+func clubSite() page.Site {
+	return page.Site{Title: conf.Club, Url: "/"}
+}
+
+// authorizedAthlete is an athlete with its strava auth
+type authorizedAthlete struct {
+	athlete db.StravaAthlete
+	auth    *db.StravaAuth
+}
+
+// selectAuthorizedAthletes returns the athlete chosen with --athlete, or
+// every athlete authorized with strava
+func selectAuthorizedAthletes(ctx context.Context) ([]authorizedAthlete, error) {
+	athletes, err := selectAthletes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []authorizedAthlete
+	for _, a := range athletes {
+		sa, err := store.SelectStravaAuthByIdContext(ctx, a.StravaId)
+		if err != nil {
+			if !db.IsEmptyResultSet(err.Error()) {
+				return nil, err
+			}
+			if rootFlags.athlete != "" {
+				return nil, fmt.Errorf("%s is not authorized, run newtoken to authorize", athleteName(a))
+			}
+			continue
+		}
+		res = append(res, authorizedAthlete{athlete: a, auth: sa})
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("strava auth user does not exist, run newtoken to authorize")
+	}
+	return res, nil
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ddominguez/run-david-run/db"
+	"github.com/spf13/cobra"
+)
+
+// categoryAuto clears a category override
+const categoryAuto = "auto"
+
+func categoryKeys() string {
+	keys := make([]string, len(db.RaceCategories))
+	for i, c := range db.RaceCategories {
+		keys[i] = c.Key
+	}
+	return strings.Join(keys, ", ")
+}
+
+var categoryCmd = &cobra.Command{
+	Use:   "category <strava activity id> [category]",
+	Short: "Show or set the race category of a saved race",
+	Long: "category will show the race category of a saved race activity.\n" +
+		"Runs are classified by distance, other sports have no category.\n" +
+		"Set a category to override it\n" +
+		"or set auto to classify the race by distance again.\n" +
+		"Categories: " + categoryKeys() + ".",
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			fmt.Printf("invalid strava activity id %s\n", args[0])
+			return
+		}
+		a, err := store.SelectRaceActivityById(id)
+		if err != nil {
+			if db.IsEmptyResultSet(err.Error()) {
+				fmt.Printf("strava activity id %d is not a saved race\n", id)
+				return
+			}
+			fmt.Println(err)
+			return
+		}
+		athletes, err := selectAthletes(cmd.Context())
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if !slices.ContainsFunc(athletes, func(ath db.StravaAthlete) bool { return ath.StravaId == a.AthleteId }) {
+			fmt.Printf("strava activity id %d is not a saved race of %s\n", id, rootFlags.athlete)
+			return
+		}
+
+		if len(args) == 2 {
+			key := args[1]
+			if key == categoryAuto {
+				key = ""
+			} else if _, ok := db.RaceCategoryByKey(key); !ok {
+				fmt.Printf("invalid category %s, expected one of %s or %s\n", key, categoryKeys(), categoryAuto)
+				return
+			}
+			if err := store.SetRaceCategory(id, key); err != nil {
+				fmt.Println(err)
+				return
+			}
+			a.CategoryOverride = key
+		}
+
+		name := a.CategoryName()
+		if name == "" {
+			name = "none"
+		}
+		source := "by distance"
+		if a.CategoryOverride != "" {
+			source = "set manually"
+		}
+		fmt.Printf("%s (%.0f m): %s, %s\n", a.Name, a.Distance, name, source)
+	},
+	Annotations: map[string]string{storeAnnotation: ""},
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+
+	"github.com/ddominguez/run-david-run/chart"
+	"github.com/ddominguez/run-david-run/db"
+	"github.com/ddominguez/run-david-run/page"
+	"github.com/ddominguez/run-david-run/utils"
+)
+
+const (
+	// chartPoints is the max number of points drawn by a line chart
+	chartPoints = 300
+	// minChartSpeed in m/s leaves out stops from the pace chart
+	minChartSpeed = 1.0
+)
+
+// unitLabel returns a tick label formatter for a unit
+func unitLabel(name string) func(float64) string {
+	return func(v float64) string {
+		return fmt.Sprintf("%g %s", v, name)
+	}
+}
+
+func paceLabel(v float64) string {
+	s := int(v + 0.5)
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+// paceChart returns the pace in seconds per distance unit over distance
+func paceChart(s db.RaceStreams, u utils.Units) chart.Line {
+	l := chart.Line{Title: "Pace", InvertY: true, XLabel: unitLabel(u.Name), YLabel: paceLabel}
+	if len(s.Distance) != len(s.VelocitySmooth) {
+		return l
+	}
+	for i, v := range s.VelocitySmooth {
+		if v < minChartSpeed {
+			continue
+		}
+		l.Points = append(l.Points, chart.Point{X: u.DistanceValue(s.Distance[i]), Y: u.DistanceMeters() / v})
+	}
+	l.Points = chart.Downsample(l.Points, chartPoints)
+
+	// ticks on whole minutes, or half minutes for an even pace
+	slowest, fastest := 0.0, math.Inf(1)
+	for _, p := range l.Points {
+		slowest, fastest = math.Max(slowest, p.Y), math.Min(fastest, p.Y)
+	}
+	l.YStep = 60 * math.Max(1, math.Ceil((slowest-fastest)/240))
+	if slowest-fastest <= 90 {
+		l.YStep = 30
+	}
+	return l
+}
+
+// speedChart returns the speed in distance units per hour over distance
+func speedChart(s db.RaceStreams, u utils.Units) chart.Line {
+	l := chart.Line{Title: "Speed", XLabel: unitLabel(u.Name), YLabel: unitLabel(u.SpeedName())}
+	if len(s.Distance) != len(s.VelocitySmooth) {
+		return l
+	}
+	for i, v := range s.VelocitySmooth {
+		l.Points = append(l.Points, chart.Point{X: u.DistanceValue(s.Distance[i]), Y: v * 3600 / u.DistanceMeters()})
+	}
+	l.Points = chart.Downsample(l.Points, chartPoints)
+	return l
+}
+
+// elevationChart returns the altitude over distance
+func elevationChart(s db.RaceStreams, u utils.Units) chart.Line {
+	l := chart.Line{Title: "Elevation", Fill: true, XLabel: unitLabel(u.Name), YLabel: unitLabel(u.ElevationName())}
+	if len(s.Distance) != len(s.Altitude) {
+		return l
+	}
+	for i, alt := range s.Altitude {
+		l.Points = append(l.Points, chart.Point{X: u.DistanceValue(s.Distance[i]), Y: u.ElevationValue(alt)})
+	}
+	l.Points = chart.Downsample(l.Points, chartPoints)
+	return l
+}
+
+// heartrateZones are the lower bounds of the heart rate zones as a
+// percentage of the max heart rate
+var heartrateZones = []struct {
+	name  string
+	min   int
+	color string
+}{
+	{"Z1", 0, "#7aa2f7"},
+	{"Z2", 60, "#9ece6a"},
+	{"Z3", 70, "#e0af68"},
+	{"Z4", 80, "#ff9e64"},
+	{"Z5", 90, "#f7768e"},
+}
+
+// maxHeartrate returns the max heart rate of the config, or the highest
+// recorded heart rate when it is not set.
+func maxHeartrate(s db.RaceStreams) int {
+	if conf.MaxHeartrate > 0 {
+		return conf.MaxHeartrate
+	}
+	var highest int
+	for _, hr := range s.Heartrate {
+		highest = max(highest, hr)
+	}
+	return highest
+}
+
+// heartrateChart returns the time spent in each heart rate zone
+func heartrateChart(s db.RaceStreams) chart.Bars {
+	c := chart.Bars{Title: "Heart Rate Zones"}
+	maxHR := maxHeartrate(s)
+	if maxHR == 0 {
+		return c
+	}
+
+	seconds := make([]int, len(heartrateZones))
+	for i, hr := range s.Heartrate {
+		// every data point lasts until the next one, or a second without time
+		d := 1
+		if len(s.Time) == len(s.Heartrate) && i+1 < len(s.Time) {
+			d = s.Time[i+1] - s.Time[i]
+		}
+		zone := 0
+		for z := range heartrateZones {
+			if hr*100 >= heartrateZones[z].min*maxHR {
+				zone = z
+			}
+		}
+		seconds[zone] += d
+	}
+
+	for z, hz := range heartrateZones {
+		label := fmt.Sprintf("%s %d+", hz.name, hz.min*maxHR/100)
+		if hz.min == 0 {
+			label = hz.name
+		}
+		c.Bars = append(c.Bars, chart.Bar{
+			Label: label,
+			Value: float64(seconds[z]),
+			Text:  utils.TimeFormatted(uint32(seconds[z])),
+			Color: hz.color,
+		})
+	}
+	return c
+}
+
+// newRaceCharts returns the charts of the streams of a race activity
+func newRaceCharts(s db.RaceStreams, u utils.Units, sportType string) []page.ChartData {
+	var res []page.ChartData
+	add := func(title string, svg template.HTML) {
+		if svg != "" {
+			res = append(res, page.ChartData{Title: title, SVG: svg})
+		}
+	}
+	if utils.UsesPace(sportType) {
+		add("Pace", paceChart(s, u).SVG())
+	} else {
+		add("Speed", speedChart(s, u).SVG())
+	}
+	add("Elevation", elevationChart(s, u).SVG())
+	add("Heart Rate Zones", heartrateChart(s).SVG())
+	return res
+}
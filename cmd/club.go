@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"sort"
+
+	"github.com/ddominguez/run-david-run/db"
+	"github.com/ddominguez/run-david-run/events"
+	"github.com/ddominguez/run-david-run/page"
+	"github.com/ddominguez/run-david-run/records"
+)
+
+// newClubData returns the club page data of the sites of several athletes.
+// activities are the races of every athlete by strava id, evs the events
+// run by several athletes.
+func newClubData(sites []athleteSite, activities map[uint64][]db.RaceActivity, evs []events.Event) (page.ClubData, error) {
+	type siteRace struct {
+		site athleteSite
+		race db.RaceActivity
+	}
+	var races []siteRace
+
+	data := page.ClubData{Site: clubSite()}
+	for _, site := range sites {
+		athleteRaces := activities[site.athlete.StravaId]
+		profile := site.athlete.ProfileMedium
+		if profile == "" {
+			profile = site.athlete.Profile
+		}
+		data.Members = append(data.Members, page.MemberData{
+			Name:    athleteName(site.athlete),
+			Profile: profile,
+			Url:     site.base,
+			Races:   len(athleteRaces),
+			Records: len(records.Compute(athleteRaces).Records),
+		})
+		for _, a := range athleteRaces {
+			races = append(races, siteRace{site, a})
+		}
+	}
+
+	for _, e := range evs {
+		data.Events = append(data.Events, newEventLink(e, sites[0].isGenerated))
+	}
+
+	// start dates are RFC3339 strings, they sort in time order
+	sort.SliceStable(races, func(i, j int) bool { return races[i].race.StartDate > races[j].race.StartDate })
+	for _, r := range races {
+		link, err := newRaceLink(r.race, r.site)
+		if err != nil {
+			return data, err
+		}
+		year, err := r.race.RaceYear()
+		if err != nil {
+			return data, err
+		}
+		data.Races = append(data.Races, page.ClubRaceData{
+			RaceLink: link,
+			Year:     year,
+			Athlete:  athleteName(r.site.athlete),
+		})
+	}
+	return data, nil
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ddominguez/run-david-run/db"
+	"github.com/ddominguez/run-david-run/events"
+	"github.com/ddominguez/run-david-run/page"
+	"github.com/ddominguez/run-david-run/utils"
+)
+
+// eventUrl returns the url of the page of an event
+func eventUrl(key string, isGenerated bool) string {
+	if isGenerated {
+		return fmt.Sprintf("/event/%s/", key)
+	}
+	return fmt.Sprintf("/event/%s", key)
+}
+
+// siteActivities returns the races of every site by athlete strava id
+func siteActivities(ctx context.Context, sites []athleteSite) (map[uint64][]db.RaceActivity, error) {
+	res := map[uint64][]db.RaceActivity{}
+	for _, site := range sites {
+		races, err := store.AthleteRaceActivitiesContext(ctx, site.athlete.StravaId)
+		if err != nil {
+			return nil, err
+		}
+		res[site.athlete.StravaId] = races
+	}
+	return res, nil
+}
+
+// clubEvents returns the events run by several athletes of the sites.
+// A single site has no events.
+func clubEvents(sites []athleteSite, activities map[uint64][]db.RaceActivity) ([]events.Event, error) {
+	if len(sites) < 2 {
+		return nil, nil
+	}
+	var all []db.RaceActivity
+	for _, site := range sites {
+		all = append(all, activities[site.athlete.StravaId]...)
+	}
+	return events.Group(all)
+}
+
+// eventHasAthlete returns true when an athlete of the sites ran the event
+func eventHasAthlete(e events.Event, sites []athleteSite) bool {
+	for _, r := range e.Results {
+		for _, site := range sites {
+			if site.athlete.StravaId == r.Race.AthleteId {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// newEventLink returns a link to the page of an event
+func newEventLink(e events.Event, isGenerated bool) page.RaceLink {
+	return page.RaceLink{Name: e.Name, Url: eventUrl(e.Key, isGenerated), Date: e.Date.Format("Jan 2, 2006")}
+}
+
+// eventLinks returns the link to the event of every race run at an event,
+// by race strava id
+func eventLinks(evs []events.Event, isGenerated bool) map[uint64]page.RaceLink {
+	res := map[uint64]page.RaceLink{}
+	for _, e := range evs {
+		link := newEventLink(e, isGenerated)
+		for _, r := range e.Results {
+			res[r.Race.StravaId] = link
+		}
+	}
+	return res
+}
+
+// newEventData returns the page data of an event run by athletes of the sites
+func newEventData(e events.Event, sites []athleteSite, d raceDisplay) (page.EventData, error) {
+	u := d.units
+	data := page.EventData{
+		Site:      clubSite(),
+		Name:      e.Name,
+		Date:      e.Date.Format("Monday, January 2, 2006"),
+		PaceLabel: "Pace",
+	}
+	if len(e.Results) == 0 {
+		return data, nil
+	}
+
+	first := e.Results[0].Race
+	data.Distance = first.CategoryName()
+	if data.Distance == "" {
+		data.Distance = u.Distance(first.Distance)
+	}
+	if !utils.UsesPace(first.SportType) {
+		data.PaceLabel = "Speed"
+	}
+
+	bySite := map[uint64]athleteSite{}
+	for _, site := range sites {
+		bySite[site.athlete.StravaId] = site
+	}
+	for _, r := range e.Results {
+		site, ok := bySite[r.Race.AthleteId]
+		if !ok {
+			return data, fmt.Errorf("race %d of event %s is not of a club athlete", r.Race.StravaId, e.Key)
+		}
+		url, err := raceUrl(r.Race, site)
+		if err != nil {
+			return data, err
+		}
+		a := r.Race
+		data.Results = append(data.Results, page.EventResultData{
+			Place:      r.Place,
+			Athlete:    athleteName(site.athlete),
+			AthleteUrl: site.base,
+			Time:       utils.TimeFormatted(a.ElapsedTime),
+			Pace:       u.PaceOrSpeed(a.SportType, a.Distance, d.raceTimeBasis.Seconds(a.ElapsedTime, a.MovingTime)),
+			Url:        url,
+		})
+	}
+	return data, nil
+}
@@ -19,7 +19,7 @@ func dateTimeToEpoch(dt string) (int64, error) {
 }
 
 func getLatestActivityEpoch(athleteId uint64) (int64, error) {
-	res, err := db.SelectLatestActivityDateTime(athleteId)
+	res, err := store.SelectLatestActivityDateTime(athleteId)
 	if err != nil {
 		fmt.Println("unable to select latest activity datetime: ", err)
 		return 0, err
@@ -32,99 +32,382 @@ func getLatestActivityEpoch(athleteId uint64) (int64, error) {
 	return dateTimeToEpoch(res)
 }
 
-var fetchCmd = &cobra.Command{
-	Use:   "fetch",
-	Short: "Fetch and save Strava race activities",
-	Long: "fetch will request activities from Strava and \n." +
-		"save the race activities.",
-	Run: func(cmd *cobra.Command, args []string) {
-		stravaAuth, err := db.SelectStravaAuth()
-		if err != nil {
-			fmt.Println(err)
+// updateLatestActivity saves the datetime as the athlete's latest activity when
+// it is later than the current one and returns the latest activity epoch.
+func updateLatestActivity(athleteId uint64, currEpoch int64, dt string) (int64, error) {
+	epoch, err := dateTimeToEpoch(dt)
+	if err != nil {
+		return currEpoch, err
+	}
+	if epoch <= currEpoch {
+		return currEpoch, nil
+	}
+	if err := store.UpdateLatestActivityDateTime(athleteId, dt); err != nil {
+		return currEpoch, err
+	}
+	return epoch, nil
+}
+
+// newRaceActivity returns the race_activity record for a strava activity
+func newRaceActivity(a strava.Activity, athleteId uint64) db.RaceActivity {
+	return db.RaceActivity{
+		StravaId:    a.Id,
+		AthleteId:   athleteId,
+		Name:        a.Name,
+		SportType:   a.SportType,
+		StartDate:   db.DateTime(a.StartDateLocal),
+		Distance:    a.Distance,
+		MovingTime:  a.MovingTime,
+		ElapsedTime: a.ElapsedTime,
+		Polyline:    a.Map.SummaryPolyline,
+		RawJSON:     string(a.Raw),
+	}
+}
+
+// newRaceDetails returns the splits, laps and best efforts of a detailed strava activity
+func newRaceDetails(a strava.Activity) db.RaceDetails {
+	var d db.RaceDetails
+	addSplits := func(kind string, splits []strava.Split) {
+		for _, s := range splits {
+			d.Splits = append(d.Splits, db.RaceSplit{
+				Kind:                kind,
+				Split:               s.Split,
+				Distance:            s.Distance,
+				ElapsedTime:         s.ElapsedTime,
+				MovingTime:          s.MovingTime,
+				ElevationDifference: s.ElevationDifference,
+				AverageSpeed:        s.AverageSpeed,
+				AverageHeartrate:    s.AverageHeartrate,
+			})
 		}
-		if !stravaAuth.Exists() {
-			fmt.Println("strava auth user does not exist")
-			return
+	}
+	addSplits(db.SplitStandard, a.SplitsStandard)
+	addSplits(db.SplitMetric, a.SplitsMetric)
+
+	for _, l := range a.Laps {
+		d.Laps = append(d.Laps, db.RaceLap{
+			LapIndex:           l.LapIndex,
+			Name:               l.Name,
+			Distance:           l.Distance,
+			ElapsedTime:        l.ElapsedTime,
+			MovingTime:         l.MovingTime,
+			TotalElevationGain: l.TotalElevationGain,
+			AverageSpeed:       l.AverageSpeed,
+			AverageHeartrate:   l.AverageHeartrate,
+		})
+	}
+	for _, e := range a.BestEfforts {
+		d.BestEfforts = append(d.BestEfforts, db.RaceBestEffort{
+			Name:        e.Name,
+			Distance:    e.Distance,
+			ElapsedTime: e.ElapsedTime,
+			MovingTime:  e.MovingTime,
+			PrRank:      e.PrRank,
+		})
+	}
+	return d
+}
+
+// fetchRaceDetails requests the detailed activity of new or changed races and
+// of races without saved details, then saves its splits, laps and best efforts.
+func fetchRaceDetails(client *strava.Client, stravaId uint64, res db.UpsertResult) error {
+	if !res.Inserted && len(res.Changes) == 0 {
+		exists, err := store.HasRaceDetails(stravaId)
+		if err != nil || exists {
+			return err
 		}
-		if stravaAuth.IsExpired() {
-			fmt.Println("strava access token is expired")
-			return
+	}
+
+	a, err := strava.GetActivity(client, stravaId)
+	if err != nil {
+		return err
+	}
+	// the detailed activity json has more fields than the summary, e.g. device_name
+	if err := store.TouchRaceActivity(db.RaceActivity{StravaId: stravaId, RawJSON: string(a.Raw)}); err != nil {
+		return err
+	}
+	return store.SaveRaceDetails(stravaId, newRaceDetails(a))
+}
+
+// fetchRaceStreams requests and saves the streams of every stored race of
+// an athlete without saved streams and returns the number of races updated.
+func fetchRaceStreams(client *strava.Client, athleteId uint64) (int, error) {
+	races, err := store.AthleteRaceActivitiesWithHidden(athleteId)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	for _, r := range races {
+		exists, err := store.HasRaceStreams(r.StravaId)
+		if err != nil {
+			return count, err
+		}
+		if exists {
+			continue
 		}
 
-		latestActivityEpoch, err := getLatestActivityEpoch(stravaAuth.AthleteId)
+		s, err := strava.GetActivityStreams(client, r.StravaId)
 		if err != nil {
-			fmt.Println(err)
-			return
+			return count, err
 		}
+		err = store.SaveRaceStreams(r.StravaId, db.RaceStreams{
+			LatLng:         s.LatLng,
+			Time:           s.Time,
+			Distance:       s.Distance,
+			Altitude:       s.Altitude,
+			Heartrate:      s.Heartrate,
+			Cadence:        s.Cadence,
+			VelocitySmooth: s.VelocitySmooth,
+		})
+		if err != nil {
+			return count, err
+		}
+		// races without streams are saved too, so they are not requested again
+		if s.Len() == 0 {
+			fmt.Printf("--- no streams for %s (strava activity id %d) ---\n", r.Name, r.StravaId)
+			continue
+		}
+		fmt.Printf("--- saved %d stream points for %s ---\n", s.Len(), r.Name)
+		count++
+	}
+	return count, nil
+}
 
-		client := strava.NewClient(stravaAuth.AccessToken)
-		var page uint16
-		var perPage uint8 = 200
-		var latestActivityDateTime string
+// fetchWindow is the time window of activities requested from strava
+type fetchWindow struct {
+	after  int64
+	before int64
+	// incremental is true when the window starts at the athlete's latest activity
+	incremental bool
+}
 
-		params := strava.ReqParams{
-			Page:    page,
-			PerPage: perPage,
-			After:   latestActivityEpoch,
+// covers returns true when the window includes everything after the
+// latest activity epoch, so the cursor can safely move past it.
+func (w fetchWindow) covers(latestEpoch int64) bool {
+	return w.before == 0 && w.after <= latestEpoch
+}
+
+var fetchFlags struct {
+	since   string
+	until   string
+	full    bool
+	refresh bool
+	streams bool
+}
+
+// parseFetchWindow returns the window requested with the --since, --until
+// and --full flags. Without them the window starts at the latest activity.
+func parseFetchWindow(latestEpoch int64) (fetchWindow, error) {
+	if fetchFlags.full && (fetchFlags.since != "" || fetchFlags.until != "") {
+		return fetchWindow{}, fmt.Errorf("--full can not be used with --since or --until")
+	}
+	if fetchFlags.full {
+		return fetchWindow{}, nil
+	}
+	if fetchFlags.since == "" && fetchFlags.until == "" {
+		return fetchWindow{after: latestEpoch, incremental: true}, nil
+	}
+
+	var w fetchWindow
+	if fetchFlags.since != "" {
+		t, err := time.Parse(time.DateOnly, fetchFlags.since)
+		if err != nil {
+			return w, fmt.Errorf("invalid --since date, expected YYYY-MM-DD: %s", err)
+		}
+		// after is exclusive, include activities starting exactly at midnight
+		w.after = t.Unix() - 1
+	}
+	if fetchFlags.until != "" {
+		t, err := time.Parse(time.DateOnly, fetchFlags.until)
+		if err != nil {
+			return w, fmt.Errorf("invalid --until date, expected YYYY-MM-DD: %s", err)
 		}
+		// until is inclusive, stop at the start of the next day
+		w.before = t.AddDate(0, 0, 1).Unix()
+	}
+	if w.before > 0 && w.before <= w.after+1 {
+		return w, fmt.Errorf("--until must not be before --since")
+	}
+	return w, nil
+}
 
-		for page = 1; true; page++ {
-			params.Page = page
-			activities, err := strava.GetActivities(client, params)
-			if err != nil {
-				fmt.Println(err)
+// fetchStats counts what happened to the race activities found by fetch
+type fetchStats struct {
+	inserted int
+	updated  int
+	skipped  int
+}
+
+func (s fetchStats) String() string {
+	return fmt.Sprintf("%d inserted, %d updated, %d skipped", s.inserted, s.updated, s.skipped)
+}
+
+// fetchAthlete fetches and saves the race activities of an authorized athlete
+func fetchAthlete(stravaAuth *db.StravaAuth, policy strava.RacePolicy) error {
+	client, err := newStravaClient(stravaAuth)
+	if err != nil {
+		return err
+	}
+
+	latestActivityEpoch, err := getLatestActivityEpoch(stravaAuth.AthleteId)
+	if err != nil {
+		return err
+	}
+
+	window, err := parseFetchWindow(latestActivityEpoch)
+	if err != nil {
+		return err
+	}
+
+	var page uint16
+	var perPage uint8 = 200
+	var stats fetchStats
+	var windowLatest string
+	var windowLatestEpoch int64
+
+	params := strava.ReqParams{
+		Page:    page,
+		PerPage: perPage,
+		After:   window.after,
+		Before:  window.before,
+	}
+
+	defer func() {
+		fmt.Println("-- races:", stats)
+		fmt.Println("-- strava api usage:", client.RateLimit())
+	}()
+
+	for page = 1; true; page++ {
+		params.Page = page
+		activities, err := strava.GetActivities(client, params)
+		if err != nil {
+			return err
+		}
+		activitiesLen := len(activities)
+		if activitiesLen == 0 {
+			fmt.Println("no more activities")
+			break
+		}
+		for _, a := range activities {
+			if epoch, err := dateTimeToEpoch(a.StartDateLocal); err == nil && epoch > windowLatestEpoch {
+				windowLatest, windowLatestEpoch = a.StartDateLocal, epoch
 			}
-			activitiesLen := len(activities)
-			if activitiesLen == 0 {
-				fmt.Println("no more activities")
-				break
+			if !policy.Match(a) {
+				continue
 			}
-			for _, a := range activities {
-				if !a.IsRace() {
-					continue
-				}
-				sid, err := db.SelectRaceActivityId(a.Id)
+			if !fetchFlags.refresh {
+				sid, err := store.SelectRaceActivityId(a.Id)
 				if err != nil && !db.IsEmptyResultSet(err.Error()) {
-					fmt.Println(err)
-					return
+					return err
 				}
 				if sid > 0 {
 					fmt.Printf("--- strava activity id %d already exists ---\n", a.Id)
+					stats.skipped++
+					// a fetch stopped after saving the race may not have saved its details
+					if err := fetchRaceDetails(client, a.Id, db.UpsertResult{}); err != nil {
+						return err
+					}
 					continue
 				}
-				dbDateTime := db.DateTime(a.StartDateLocal)
-				err = db.InsertRaceActivity(db.RaceActivity{
-					StravaId:    a.Id,
-					AthleteId:   stravaAuth.AthleteId,
-					Name:        a.Name,
-					StartDate:   dbDateTime,
-					Distance:    a.Distance,
-					MovingTime:  a.MovingTime,
-					ElapsedTime: a.ElapsedTime,
-					Polyline:    a.Map.SummaryPolyline,
-				})
-				if err != nil {
-					fmt.Println("unable to insert new race activity", err)
-					return
-				}
-				fmt.Println(a.Name)
 			}
-			latestActivityDateTime = activities[activitiesLen-1].StartDateLocal
-		}
-
-		if latestActivityDateTime != "" {
-			currEpoch, err := dateTimeToEpoch(latestActivityDateTime)
+			res, err := store.UpsertRaceActivity(newRaceActivity(a, stravaAuth.AthleteId))
 			if err != nil {
-				fmt.Println(err)
+				return fmt.Errorf("unable to save race activity %w", err)
 			}
-			if currEpoch != latestActivityEpoch {
-				err = db.UpdateLatestActivityDateTime(stravaAuth.AthleteId, latestActivityDateTime)
-				if err != nil {
-					fmt.Println(err)
+			switch {
+			case res.Inserted:
+				stats.inserted++
+				fmt.Println(a.Name)
+			case len(res.Changes) > 0:
+				stats.updated++
+				fmt.Printf("--- updated %s (strava activity id %d) ---\n", a.Name, a.Id)
+				for _, c := range res.Changes {
+					fmt.Println("    ", c)
 				}
+			default:
+				stats.skipped++
+			}
+
+			if err := fetchRaceDetails(client, a.Id, res); err != nil {
+				return err
 			}
 		}
 
+		if !window.incremental {
+			continue
+		}
+		// activities requested with only `after` are sorted oldest first,
+		// so the cursor can move forward after every page and an
+		// interrupted fetch resumes where it stopped.
+		latestActivityEpoch, err = updateLatestActivity(
+			stravaAuth.AthleteId, latestActivityEpoch, activities[activitiesLen-1].StartDateLocal,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	// a completed re-sync only moves the cursor forward, and only when
+	// nothing between the cursor and the end of the window was missed
+	if !window.incremental && windowLatest != "" && window.covers(latestActivityEpoch) {
+		_, err = updateLatestActivity(stravaAuth.AthleteId, latestActivityEpoch, windowLatest)
+		if err != nil {
+			return err
+		}
+	}
+
+	if fetchFlags.streams {
+		n, err := fetchRaceStreams(client, stravaAuth.AthleteId)
+		fmt.Println("-- streams:", n, "races updated")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var fetchCmd = &cobra.Command{
+	Use:   "fetch",
+	Short: "Fetch and save Strava race activities",
+	Long: "fetch will request activities from Strava and\n" +
+		"save the race activities of every authorized athlete, or of --athlete.\n" +
+		"By default only activities after the latest fetched activity are requested.\n" +
+		"Use --since and --until to re-sync a date range or --full to re-sync everything.\n" +
+		"Use --refresh to update stored races that changed in Strava.\n" +
+		"Use --streams to download the gps, heart rate and other streams of stored races.\n" +
+		"The races settings of the config select which activities are races,\n" +
+		"the race policy flags override them for one run.",
+	Run: func(cmd *cobra.Command, args []string) {
+		policy := racePolicy()
+		athletes, err := selectAuthorizedAthletes(cmd.Context())
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		for _, a := range athletes {
+			fmt.Printf("-- fetching races of %s --\n", athleteName(a.athlete))
+			err := fetchAthlete(a.auth, policy)
+			if err == nil {
+				continue
+			}
+			printStravaError("fetch", err)
+			// other athletes can still be fetched when one revoked access
+			if !strava.IsUnauthorized(err) {
+				return
+			}
+		}
 		fmt.Println("-- done ---")
 	},
+	Annotations: map[string]string{storeAnnotation: ""},
+}
+
+func init() {
+	fetchCmd.Flags().StringVar(&fetchFlags.since, "since", "", "re-sync activities starting on or after this date (YYYY-MM-DD)")
+	fetchCmd.Flags().StringVar(&fetchFlags.until, "until", "", "re-sync activities starting on or before this date (YYYY-MM-DD)")
+	fetchCmd.Flags().BoolVar(&fetchFlags.full, "full", false, "re-sync all activities")
+	fetchCmd.Flags().BoolVar(&fetchFlags.refresh, "refresh", false, "update stored races that changed in strava")
+	fetchCmd.Flags().BoolVar(&fetchFlags.streams, "streams", false, "download streams for stored races without them")
+	addRacePolicyFlags(fetchCmd)
 }
@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/ddominguez/run-david-run/db"
+	"github.com/ddominguez/run-david-run/strava"
+)
+
+func newActivity(id uint64, name, sportType string, workoutType uint8, start string) strava.Activity {
+	a := strava.Activity{
+		Id:             id,
+		Name:           name,
+		Distance:       5000,
+		MovingTime:     1200,
+		ElapsedTime:    1230,
+		SportType:      sportType,
+		WorkoutType:    workoutType,
+		StartDateLocal: start,
+	}
+	a.Map.SummaryPolyline = "_p~iF~ps|U_ulLnnqC_mqNvxq`@"
+	a.SplitsStandard = []strava.Split{{Split: 1, Distance: 1609, ElapsedTime: 400}}
+	return a
+}
+
+// raceIds returns the ids of the saved races, newest first
+func raceIds(t *testing.T) []uint64 {
+	t.Helper()
+	races, err := store.AllRaceActivities()
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	var ids []uint64
+	for _, r := range races {
+		ids = append(ids, r.StravaId)
+	}
+	return ids
+}
+
+func equalIds(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFetch(t *testing.T) {
+	srv := newTestEnv(t)
+	authorizeAthlete(t, srv, db.StravaAthlete{StravaId: 1, FirstName: "Test"})
+
+	race := newActivity(1, "Turkey Trot", "Run", strava.WorkoutTypeRunRace, "2023-11-23T08:00:00Z")
+	race.BestEfforts = []strava.BestEffort{{Name: "1 mile", Distance: 1609, ElapsedTime: 390}}
+	srv.AddActivities(
+		race,
+		newActivity(2, "Easy Run", "Run", 0, "2023-11-25T08:00:00Z"),
+		newActivity(3, "Gran Fondo", "Ride", 11, "2023-11-26T08:00:00Z"),
+	)
+
+	runCmd(fetchCmd)
+	if ids := raceIds(t); !equalIds(ids, []uint64{1}) {
+		t.Fatalf("Incorrect races. Found(%v), Expected(%v)", ids, []uint64{1})
+	}
+	if exists, err := store.HasRaceDetails(1); err != nil || !exists {
+		t.Errorf("Expected the details of the race. Found(%v, %v)", exists, err)
+	}
+	// the cursor moves past every activity, not only races
+	if dt, _ := store.SelectLatestActivityDateTime(1); dt != "2023-11-26T08:00:00Z" {
+		t.Errorf("Incorrect latest activity. Found(%s), Expected(%s)", dt, "2023-11-26T08:00:00Z")
+	}
+
+	// an expired token is refreshed and saved, only new activities are requested
+	detailRequests := srv.Requests("GET /api/v3/activities/{id}")
+	srv.ExpireToken()
+	expired := srv.Token()
+	err := store.UpdateStravaAuth(db.StravaAuth{
+		AccessToken:  expired.AccessToken,
+		RefreshToken: expired.RefreshToken,
+		ExpiresAt:    expired.ExpiresAt,
+		AthleteId:    1,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	srv.AddActivities(newActivity(4, "Jingle Bell Run", "Run", strava.WorkoutTypeRunRace, "2023-12-10T08:00:00Z"))
+	runCmd(fetchCmd)
+	if ids := raceIds(t); !equalIds(ids, []uint64{4, 1}) {
+		t.Errorf("Incorrect races. Found(%v), Expected(%v)", ids, []uint64{4, 1})
+	}
+	if n := srv.Requests("GET /api/v3/activities/{id}") - detailRequests; n != 1 {
+		t.Errorf("Incorrect number of detailed activity requests. Found(%d), Expected(%d)", n, 1)
+	}
+	if srv.Refreshes() != 1 {
+		t.Errorf("Incorrect number of token refreshes. Found(%d), Expected(%d)", srv.Refreshes(), 1)
+	}
+	auth, err := store.SelectStravaAuthById(1)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if auth.AccessToken != srv.Token().AccessToken {
+		t.Errorf("Incorrect saved access token. Found(%s), Expected(%s)", auth.AccessToken, srv.Token().AccessToken)
+	}
+}
+
+func TestFetchStreams(t *testing.T) {
+	srv := newTestEnv(t)
+	authorizeAthlete(t, srv, db.StravaAthlete{StravaId: 1, FirstName: "Test"})
+	srv.AddActivities(
+		newActivity(1, "Turkey Trot", "Run", strava.WorkoutTypeRunRace, "2023-11-23T08:00:00Z"),
+		newActivity(2, "Treadmill 5K", "Run", strava.WorkoutTypeRunRace, "2023-11-25T08:00:00Z"),
+	)
+	srv.SetStreams(1, strava.Streams{Time: []int{0, 10}, Distance: []float64{0, 31.4}})
+
+	fetchFlags.streams = true
+	runCmd(fetchCmd)
+	if n := srv.Requests("GET /api/v3/activities/{id}/streams"); n != 2 {
+		t.Errorf("Incorrect number of stream requests. Found(%d), Expected(%d)", n, 2)
+	}
+	for _, id := range []uint64{1, 2} {
+		if exists, err := store.HasRaceStreams(id); err != nil || !exists {
+			t.Errorf("Expected the streams of race %d. Found(%v, %v)", id, exists, err)
+		}
+	}
+
+	// races without streams are not requested again
+	runCmd(fetchCmd)
+	if n := srv.Requests("GET /api/v3/activities/{id}/streams"); n != 2 {
+		t.Errorf("Incorrect number of stream requests. Found(%d), Expected(%d)", n, 2)
+	}
+}
+
+func TestParseFetchWindow(t *testing.T) {
+	prev := fetchFlags
+	t.Cleanup(func() { fetchFlags = prev })
+
+	// 2024-01-01T00:00:00Z and 2024-02-01T00:00:00Z
+	jan, feb := int64(1704067200), int64(1706745600)
+	testCases := []struct {
+		name     string
+		since    string
+		until    string
+		full     bool
+		expected fetchWindow
+		err      bool
+	}{
+		{"incremental", "", "", false, fetchWindow{after: 100, incremental: true}, false},
+		{"full", "", "", true, fetchWindow{}, false},
+		{"since", "2024-01-01", "", false, fetchWindow{after: jan - 1}, false},
+		{"until", "", "2024-01-31", false, fetchWindow{before: feb}, false},
+		{"since and until", "2024-01-01", "2024-01-31", false, fetchWindow{after: jan - 1, before: feb}, false},
+		{"one day", "2024-01-31", "2024-01-31", false, fetchWindow{after: feb - 86400 - 1, before: feb}, false},
+		{"full and since", "2024-01-01", "", true, fetchWindow{}, true},
+		{"full and until", "", "2024-01-31", true, fetchWindow{}, true},
+		{"invalid since", "01/01/2024", "", false, fetchWindow{}, true},
+		{"invalid until", "", "2024-02-30", false, fetchWindow{}, true},
+		{"until before since", "2024-01-31", "2024-01-01", false, fetchWindow{}, true},
+	}
+	for _, tc := range testCases {
+		fetchFlags.since, fetchFlags.until, fetchFlags.full = tc.since, tc.until, tc.full
+		w, err := parseFetchWindow(100)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: expected an error. Found(%+v)", tc.name, w)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error. %s", tc.name, err)
+			continue
+		}
+		if w != tc.expected {
+			t.Errorf("%s: incorrect window. Found(%+v), Expected(%+v)", tc.name, w, tc.expected)
+		}
+	}
+}
+
+func TestFetchWindow(t *testing.T) {
+	srv := newTestEnv(t)
+	authorizeAthlete(t, srv, db.StravaAthlete{StravaId: 1, FirstName: "Test"})
+	srv.AddActivities(
+		newActivity(1, "Turkey Trot", "Run", strava.WorkoutTypeRunRace, "2023-11-23T08:00:00Z"),
+		newActivity(2, "Jingle Bell Run", "Run", strava.WorkoutTypeRunRace, "2023-12-10T08:00:00Z"),
+	)
+
+	// the daily limit is reached at the details of the second race, the
+	// cursor must not move past the page that was not finished
+	srv.SetRateLimit(200, 2)
+	runCmd(fetchCmd)
+	if dt, _ := store.SelectLatestActivityDateTime(1); dt != "" {
+		t.Errorf("Expected the cursor to stay after a partial fetch. Found(%s)", dt)
+	}
+	if exists, _ := store.HasRaceDetails(2); exists {
+		t.Fatalf("Expected the second race without details")
+	}
+
+	// the next fetch resumes and saves the missing details
+	srv.SetRateLimit(200, 2000)
+	runCmd(fetchCmd)
+	if dt, _ := store.SelectLatestActivityDateTime(1); dt != "2023-12-10T08:00:00Z" {
+		t.Errorf("Incorrect latest activity. Found(%s), Expected(%s)", dt, "2023-12-10T08:00:00Z")
+	}
+	if exists, err := store.HasRaceDetails(2); err != nil || !exists {
+		t.Errorf("Expected the details of the second race. Found(%v, %v)", exists, err)
+	}
+
+	// an older race is only found by a re-sync, which does not move the cursor
+	srv.AddActivities(newActivity(3, "Spring 5K", "Run", strava.WorkoutTypeRunRace, "2023-04-15T08:00:00Z"))
+	runCmd(fetchCmd)
+	if ids := raceIds(t); !equalIds(ids, []uint64{2, 1}) {
+		t.Errorf("Incorrect races after an incremental fetch. Found(%v), Expected(%v)", ids, []uint64{2, 1})
+	}
+	fetchFlags.since, fetchFlags.until = "2023-04-01", "2023-04-30"
+	runCmd(fetchCmd)
+	if ids := raceIds(t); !equalIds(ids, []uint64{2, 1, 3}) {
+		t.Errorf("Incorrect races after a re-sync. Found(%v), Expected(%v)", ids, []uint64{2, 1, 3})
+	}
+	if dt, _ := store.SelectLatestActivityDateTime(1); dt != "2023-12-10T08:00:00Z" {
+		t.Errorf("Expected the cursor to stay after a re-sync. Found(%s)", dt)
+	}
+}
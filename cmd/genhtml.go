@@ -7,72 +7,207 @@ import (
 
 	"github.com/ddominguez/run-david-run/db"
 	"github.com/ddominguez/run-david-run/page"
-	"github.com/ddominguez/run-david-run/utils"
+	"github.com/ddominguez/run-david-run/records"
+	"github.com/ddominguez/run-david-run/stats"
 	"github.com/spf13/cobra"
 )
 
+// generateFilterFiles generates <dir>/<key>/index.html for every filter
+func generateFilterFiles(tmpl *page.Tmpl, dir string, filters []page.Filter, data func(key string) page.IndexData) error {
+	for _, f := range filters {
+		file := path.Join(dir, f.Key, "index.html")
+		if err := os.MkdirAll(path.Dir(file), 0770); err != nil {
+			return fmt.Errorf("failed to create path %s", err)
+		}
+		if err := tmpl.Generate(file, "base", data(f.Key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateSite generates the race, index, records, year, sport and
+// category pages of the site of an athlete. eventLinks link the races run
+// at an event to its page.
+func generateSite(site athleteSite, activities []db.RaceActivity, display raceDisplay, eventLinks map[uint64]page.RaceLink) error {
+	indexTmpl := page.New(conf.TemplateFiles("base.html", "index.html"))
+	raceTmpl := page.New(conf.TemplateFiles("base.html", "race.html"))
+	recordsTmpl := page.New(conf.TemplateFiles("base.html", "records.html"))
+	yearTmpl := page.New(conf.TemplateFiles("base.html", "year.html"))
+	prs := records.Compute(activities)
+	dir := path.Join(conf.Dist, site.base)
+
+	// generate race files
+	for _, a := range activities {
+		raceYear, err := a.RaceYear()
+		if err != nil {
+			return err
+		}
+
+		racefile := path.Join(dir, fmt.Sprintf("%d", raceYear), a.NameSlugified(), "index.html")
+		if err := os.MkdirAll(path.Dir(racefile), 0770); err != nil {
+			return fmt.Errorf("failed to create path %s", err)
+		}
+
+		data, err := newRaceData(a, display, site)
+		if err != nil {
+			return err
+		}
+		data.SetPR = prs.SetPR(a.StravaId)
+		data.Event = eventLinks[a.StravaId]
+		if err := raceTmpl.Generate(racefile, "base", data); err != nil {
+			return err
+		}
+	}
+
+	// generate index file
+	data := newIndexData(activities, "", "", site)
+	indexFile := path.Join(dir, "index.html")
+	if err := os.MkdirAll(dir, 0770); err != nil {
+		return fmt.Errorf("failed to create path %s", err)
+	}
+	if err := indexTmpl.Generate(indexFile, "base", data); err != nil {
+		return err
+	}
+
+	// generate records file
+	recordsData, err := newRecordsData(activities, display.units, site)
+	if err != nil {
+		return err
+	}
+	recordsFile := path.Join(dir, "records", "index.html")
+	if err := os.MkdirAll(path.Dir(recordsFile), 0770); err != nil {
+		return fmt.Errorf("failed to create path %s", err)
+	}
+	if err := recordsTmpl.Generate(recordsFile, "base", recordsData); err != nil {
+		return err
+	}
+
+	// generate year files
+	years, err := stats.ByYear(activities)
+	if err != nil {
+		return err
+	}
+	for _, y := range years {
+		yearData, err := newYearData(y, years, display.units, site)
+		if err != nil {
+			return err
+		}
+		yearFile := path.Join(dir, fmt.Sprintf("%d", y.Year), "index.html")
+		if err := yearTmpl.Generate(yearFile, "base", yearData); err != nil {
+			return err
+		}
+	}
+
+	// generate sport and category index files
+	if data.HasSportFilter() {
+		err = generateFilterFiles(indexTmpl, path.Join(dir, "sport"), data.Sports, func(key string) page.IndexData {
+			return newIndexData(activities, key, "", site)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if data.HasCategoryFilter() {
+		err = generateFilterFiles(indexTmpl, path.Join(dir, "category"), data.Categories, func(key string) page.IndexData {
+			return newIndexData(activities, "", key, site)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 var genHtmlCmd = &cobra.Command{
 	Use:   "genhtml",
 	Short: "Generate html for saved race activities",
-	Long:  "genhtml will generate static html for saved race activities.",
+	Long: "genhtml will generate static html for saved race activities.\n" +
+		"With several athletes every athlete gets a site under athlete/<name>/\n" +
+		"and the index page lists the races of the club. Races run by several\n" +
+		"athletes are grouped into event pages under event/<key>/. Use --athlete to\n" +
+		"generate the site of one athlete only.\n" +
+		"Race maps are svg images by default, use --map mapbox for mapbox static images.\n" +
+		"Use --privacy-zone and --hide-ends to hide where routes start and finish.\n" +
+		"Use --units km for kilometers and meters, and --race-pace-time and\n" +
+		"--split-pace-time to compute paces with the moving time.\n" +
+		"These flags override the map, units and pace time settings of the config.",
 	Run: func(cmd *cobra.Command, args []string) {
-		activities, err := db.AllRaceActivities()
+		if err := checkMapConfig(); err != nil {
+			fmt.Println(err)
+			return
+		}
+		display := newRaceDisplay()
+
+		selected, sites, err := selectSites(cmd.Context(), true)
 		if err != nil {
 			fmt.Println(err)
 			return
 		}
+		if len(sites) == 0 {
+			fmt.Println("there are no athletes, run newtoken to authorize one")
+			return
+		}
 
-		indexTmpl := page.New([]string{"templates/base.html", "templates/index.html"})
-		raceTmpl := page.New([]string{"templates/base.html", "templates/race.html"})
+		// events and their links need the races of every athlete
+		activities, err := siteActivities(cmd.Context(), sites)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		evs, err := clubEvents(sites, activities)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		links := eventLinks(evs, true)
+		for _, site := range selected {
+			if err := generateSite(site, activities[site.athlete.StravaId], display, links); err != nil {
+				fmt.Println(err)
+				return
+			}
+		}
 
-		// generate race files
-		for _, a := range activities {
-			raceYear, err := a.RaceYear()
+		// the club file lists every athlete, it is not generated for --athlete
+		if len(sites) > 1 && len(selected) == len(sites) {
+			data, err := newClubData(sites, activities, evs)
 			if err != nil {
 				fmt.Println(err)
 				return
 			}
-
-			racefile := path.Join("./dist", fmt.Sprintf("%d", raceYear), a.NameSlugified(), "index.html")
-			if err := os.MkdirAll(path.Dir(racefile), 0770); err != nil {
-				fmt.Printf("failed to create path %s\n", err)
+			clubTmpl := page.New(conf.TemplateFiles("base.html", "club.html"))
+			if err := clubTmpl.Generate(path.Join(conf.Dist, "index.html"), "base", data); err != nil {
+				fmt.Println(err)
 				return
 			}
+		}
 
-			startDate, err := a.StartDateFormatted()
+		// generate the event files with a selected athlete
+		eventTmpl := page.New(conf.TemplateFiles("base.html", "event.html"))
+		for _, e := range evs {
+			if !eventHasAthlete(e, selected) {
+				continue
+			}
+			data, err := newEventData(e, sites, display)
 			if err != nil {
 				fmt.Println(err)
 				return
 			}
-
-			data := page.RaceData{
-				Name:      a.Name,
-				StartDate: startDate,
-				Distance:  utils.ActivityDistance(a.Distance),
-				Pace:      utils.ActivityPace(a.Distance, a.ElapsedTime),
-				Time:      utils.TimeFormatted(a.ElapsedTime),
-				MapboxUrl: utils.MapboxURL(a.Polyline),
+			eventFile := path.Join(conf.Dist, "event", e.Key, "index.html")
+			if err := os.MkdirAll(path.Dir(eventFile), 0770); err != nil {
+				fmt.Printf("failed to create path %s\n", err)
+				return
 			}
-			err = raceTmpl.Generate(racefile, "base", data)
-			if err != nil {
+			if err := eventTmpl.Generate(eventFile, "base", data); err != nil {
 				fmt.Println(err)
 				return
 			}
 		}
-
-		// generate index file
-		data := struct {
-			Activities  []db.RaceActivity
-			IsGenerated bool
-		}{
-			Activities:  activities,
-			IsGenerated: true,
-		}
-		indexFile := path.Join("./dist", "index.html")
-		err = indexTmpl.Generate(indexFile, "base", data)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
 	},
+	Annotations: map[string]string{storeAnnotation: ""},
+}
+
+func init() {
+	addMapFlags(genHtmlCmd)
+	addUnitsFlags(genHtmlCmd)
 }
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ddominguez/run-david-run/db"
+	"github.com/ddominguez/run-david-run/page"
+	"github.com/ddominguez/run-david-run/records"
+)
+
+// sportUrl returns the url of the index page listing races of one sport
+func sportUrl(slug string, site athleteSite) string {
+	if site.isGenerated {
+		return fmt.Sprintf("%ssport/%s/", site.base, slug)
+	}
+	return fmt.Sprintf("%s?sport=%s", site.base, slug)
+}
+
+// sportFilters returns a filter for every sport in activities, in order of first appearance
+func sportFilters(activities []db.RaceActivity, activeSlug string, site athleteSite) []page.Filter {
+	var filters []page.Filter
+	seen := map[string]bool{}
+	for _, a := range activities {
+		slug := a.SportSlugified()
+		if seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		filters = append(filters, page.Filter{
+			Key:    slug,
+			Name:   a.SportName(),
+			Url:    sportUrl(slug, site),
+			Active: slug == activeSlug,
+		})
+	}
+	return filters
+}
+
+// filterBySport returns the activities of one sport, or all activities when sportSlug is empty
+func filterBySport(activities []db.RaceActivity, sportSlug string) []db.RaceActivity {
+	if sportSlug == "" {
+		return activities
+	}
+	var res []db.RaceActivity
+	for _, a := range activities {
+		if a.SportSlugified() == sportSlug {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+// categoryUrl returns the url of the index page listing races of one category
+func categoryUrl(key string, site athleteSite) string {
+	if site.isGenerated {
+		return fmt.Sprintf("%scategory/%s/", site.base, key)
+	}
+	return fmt.Sprintf("%s?category=%s", site.base, key)
+}
+
+// categoryFilters returns a filter for every race category in activities,
+// from the shortest to the longest distance
+func categoryFilters(activities []db.RaceActivity, activeKey string, site athleteSite) []page.Filter {
+	found := map[string]bool{}
+	for _, a := range activities {
+		if c, ok := a.Category(); ok {
+			found[c.Key] = true
+		}
+	}
+
+	var filters []page.Filter
+	for _, c := range db.RaceCategories {
+		if !found[c.Key] {
+			continue
+		}
+		filters = append(filters, page.Filter{
+			Key:    c.Key,
+			Name:   c.Name,
+			Url:    categoryUrl(c.Key, site),
+			Active: c.Key == activeKey,
+		})
+	}
+	return filters
+}
+
+// filterByCategory returns the activities of one race category, or all activities when key is empty
+func filterByCategory(activities []db.RaceActivity, key string) []db.RaceActivity {
+	if key == "" {
+		return activities
+	}
+	var res []db.RaceActivity
+	for _, a := range activities {
+		if c, ok := a.Category(); ok && c.Key == key {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+// newIndexData returns the index page data for all activities of a site filtered by sport and race category
+func newIndexData(activities []db.RaceActivity, sportSlug, categoryKey string, site athleteSite) page.IndexData {
+	return page.IndexData{
+		Site:        site.page(),
+		Activities:  filterByCategory(filterBySport(activities, sportSlug), categoryKey),
+		IsGenerated: site.isGenerated,
+		AllUrl:      site.base,
+		Sports:      sportFilters(activities, sportSlug, site),
+		Categories:  categoryFilters(activities, categoryKey, site),
+		RecordsUrl:  recordsUrl(site),
+		PRs:         records.Compute(activities).PRs(),
+	}
+}
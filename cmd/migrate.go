@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var migrateDownAll bool
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate the schema of the database",
+	Long: "migrate will apply or roll back the schema migrations built into races.\n" +
+		"Run migrate up to create a new database or to update it after upgrading races.",
+	Annotations: map[string]string{storeAnnotation: ""},
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Apply the pending migrations",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		applied, err := store.MigrateUpContext(cmd.Context())
+		for _, m := range applied {
+			fmt.Printf("applied %d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if len(applied) == 0 {
+			fmt.Println("database is up to date")
+		}
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Roll back the newest applied migration",
+	Long: "down will roll back the newest applied migration.\n" +
+		"Use --all to roll back every migration, this deletes all saved data.",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		for {
+			m, ok, err := store.MigrateDownContext(cmd.Context())
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if !ok {
+				fmt.Println("no migration to roll back")
+				return
+			}
+			fmt.Printf("rolled back %d_%s\n", m.Version, m.Name)
+			if !migrateDownAll {
+				return
+			}
+		}
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "List the migrations and whether they were applied",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		status, err := store.MigrationStatusContext(cmd.Context())
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		for _, m := range status {
+			state := "pending"
+			if m.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%-8s %d_%s\n", state, m.Version, m.Name)
+		}
+	},
+}
+
+// isMigrateCmd returns true for the migrate command and its subcommands,
+// which run before the database is at the expected version
+func isMigrateCmd(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c == migrateCmd {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	migrateDownCmd.Flags().BoolVar(&migrateDownAll, "all", false, "roll back every migration")
+	migrateCmd.AddCommand(migrateUpCmd, migrateDownCmd, migrateStatusCmd)
+}
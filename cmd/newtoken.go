@@ -2,9 +2,9 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
 
 	"github.com/ddominguez/run-david-run/db"
 	"github.com/ddominguez/run-david-run/strava"
@@ -27,27 +27,15 @@ func getAccessToken(code string, oauth strava.Authorization) (strava.AuthTokenRe
 	return resp, nil
 }
 
-func getStravaClientCreds() (string, string, error) {
-	clientId := os.Getenv("STRAVA_CLIENT_ID")
-	if clientId == "" {
-		return "", "", fmt.Errorf("missing strava client id")
-	}
-
-	clientSecret := os.Getenv("STRAVA_CLIENT_SECRET")
-	if clientSecret == "" {
-		return "", "", fmt.Errorf("missing strava client secret")
-	}
-
-	return clientId, clientSecret, nil
-}
-
 func runServer(oauth strava.Authorization) strava.AuthTokenResp {
 	fmt.Println("-- waiting for strava authorization --")
 	fmt.Println(oauth.Url())
 
+	// the config is validated at startup, the port is always valid
+	port, _ := conf.RedirectPort()
 	mux := http.NewServeMux()
 	srv := http.Server{
-		Addr:    ":8080",
+		Addr:    ":" + port,
 		Handler: mux,
 	}
 	ctx, cancel := context.WithCancel(context.Background())
@@ -58,7 +46,13 @@ func runServer(oauth strava.Authorization) strava.AuthTokenResp {
 		oauthResp, err = getAccessToken(r.URL.Query().Get("code"), oauth)
 		if err != nil {
 			fmt.Println(err)
-			http.Error(w, fmt.Sprintf("%s", err), http.StatusBadRequest)
+			status := http.StatusBadRequest
+			var apiErr *strava.APIError
+			if errors.As(err, &apiErr) && !strava.IsUnauthorized(err) {
+				// strava failed rather than rejecting the code
+				status = http.StatusBadGateway
+			}
+			http.Error(w, fmt.Sprintf("%s", err), status)
 			cancel()
 			return
 		}
@@ -82,92 +76,105 @@ func runServer(oauth strava.Authorization) strava.AuthTokenResp {
 	return oauthResp
 }
 
+// saveAuthorization saves the tokens of an athlete, and the athlete's
+// profile when the athlete is new. Refreshed tokens come without the
+// athlete's profile.
+func saveAuthorization(resp strava.AuthTokenResp, athleteId uint64) error {
+	auth := db.StravaAuth{
+		AccessToken:  resp.AccessToken,
+		RefreshToken: resp.RefreshToken,
+		ExpiresAt:    resp.ExpiresAt,
+		AthleteId:    athleteId,
+	}
+	_, err := store.SelectStravaAuthById(athleteId)
+	switch {
+	case err == nil:
+		err = store.UpdateStravaAuth(auth)
+	case db.IsEmptyResultSet(err.Error()):
+		fmt.Println("-- inserting strava auth")
+		err = store.InsertStravaAuth(auth)
+	}
+	if err != nil {
+		return err
+	}
+
+	if resp.Athlete.Id != athleteId {
+		return nil
+	}
+	_, err = store.SelectStravaAthleteById(athleteId)
+	if err == nil || !db.IsEmptyResultSet(err.Error()) {
+		return err
+	}
+	fmt.Println("-- inserting strava athlete")
+	return store.InsertStravaAthelete(db.StravaAthlete{
+		StravaId:      resp.Athlete.Id,
+		FirstName:     resp.Athlete.FirstName,
+		LastName:      resp.Athlete.LastName,
+		Profile:       resp.Athlete.Profile,
+		ProfileMedium: resp.Athlete.ProfileMedium,
+	})
+}
+
 var newTokenCmd = &cobra.Command{
 	Use:   "newtoken",
 	Short: "Get new access and refresh tokens from Strava",
 	Long: "newtoken will request new access and refresh tokens from Strava.\n" +
-		"The access token is needed for Strava API requests.",
+		"The access token is needed for Strava API requests.\n" +
+		"Without --athlete a new athlete authorizes the app in the browser,\n" +
+		"with --athlete the tokens of that athlete are refreshed.",
 	Run: func(cmd *cobra.Command, args []string) {
-		var err error
-
-		clientId, clientSecret, err := getStravaClientCreds()
+		oauth, err := newStravaAuthorization()
 		if err != nil {
 			fmt.Println(err)
 			return
 		}
-		oauth := strava.Authorization{
-			ClientId:     clientId,
-			ClientSecret: clientSecret,
-			RedirectUri:  "http://localhost:8080/callback",
-			Scope:        "activity:read_all",
-		}
 
-		oauthUser, err := db.SelectStravaAuth()
-		if err != nil {
-			if db.IsEmptyResultSet(err.Error()) {
-				fmt.Println("-- strava auth user not found")
-			} else {
-				fmt.Println(err)
+		if rootFlags.athlete == "" {
+			fmt.Println("-- authorizing a new athlete")
+			resp := runServer(*oauth)
+			if resp.AccessToken == "" {
+				fmt.Println("strava authorization failed")
 				return
 			}
-		}
-
-		if oauthUser.AthleteId == 0 {
-			fmt.Println("-- inserting strava auth")
-			runServer(oauth)
-			err = db.InsertStravaAuth(
-				db.StravaAuth{
-					AccessToken:  oauthResp.AccessToken,
-					RefreshToken: oauthResp.RefreshToken,
-					ExpiresAt:    oauthResp.ExpiresAt,
-					AthleteId:    oauthResp.Athlete.Id,
-				},
-			)
-		} else {
-			fmt.Println("-- refreshing access token")
-			oauthResp, err = oauth.RefreshToken(oauthUser.RefreshToken)
-			if err != nil {
+			if err := saveAuthorization(resp, resp.Athlete.Id); err != nil {
 				fmt.Println(err)
 				return
 			}
-			// refresh token does not return athlete data
-			err = db.UpdateStravaAuth(
-				db.StravaAuth{
-					AccessToken:  oauthResp.AccessToken,
-					RefreshToken: oauthResp.RefreshToken,
-					ExpiresAt:    oauthResp.ExpiresAt,
-					AthleteId:    oauthUser.AthleteId,
-				},
-			)
+			fmt.Printf("-- new strava access token acquired for %s %s\n", resp.Athlete.FirstName, resp.Athlete.LastName)
+			return
 		}
+
+		athletes, err := selectAuthorizedAthletes(cmd.Context())
 		if err != nil {
 			fmt.Println(err)
 			return
 		}
-
-		_, err = db.SelectStravaAthleteById(oauthUser.AthleteId)
-		athleteExists := true
-		if err != nil {
-			if db.IsEmptyResultSet(err.Error()) {
-				athleteExists = false
-				fmt.Println("-- strava athlete not found")
-			} else {
-				fmt.Println(err)
+		a := athletes[0]
+
+		fmt.Printf("-- refreshing access token of %s\n", athleteName(a.athlete))
+		resp, err := oauth.RefreshToken(a.auth.RefreshToken)
+		if strava.IsUnauthorized(err) {
+			fmt.Println("-- refresh token is invalid or was revoked, authorizing again")
+			resp = runServer(*oauth)
+			if resp.AccessToken == "" {
+				fmt.Println("strava authorization failed")
 				return
 			}
+			if resp.Athlete.Id != a.athlete.StravaId {
+				fmt.Printf("strava authorization is of athlete %d, expected %d\n", resp.Athlete.Id, a.athlete.StravaId)
+				return
+			}
+			err = nil
 		}
-
-		if !athleteExists && oauthResp.Athlete.Id > 0 {
-			fmt.Println("-- inserting strava athlete")
-			_ = db.InsertStravaAthelete(db.StravaAthlete{
-				StravaId:      oauthResp.Athlete.Id,
-				FirstName:     oauthResp.Athlete.FirstName,
-				LastName:      oauthResp.Athlete.LastName,
-				Profile:       oauthResp.Athlete.Profile,
-				ProfileMedium: oauthResp.Athlete.ProfileMedium,
-			})
+		if err != nil {
+			fmt.Println(err)
+			return
 		}
-
-		fmt.Println("-- new strava access token acquired")
+		if err := saveAuthorization(resp, a.athlete.StravaId); err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("-- new strava access token acquired for %s\n", athleteName(a.athlete))
 	},
+	Annotations: map[string]string{storeAnnotation: ""},
 }
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ddominguez/run-david-run/strava"
+)
+
+// freePort returns a local port that is not in use
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestNewToken(t *testing.T) {
+	srv := newTestEnv(t)
+	srv.SetAthlete(strava.Athlete{Id: 7, FirstName: "Ann", LastName: "Lee"})
+	conf.Strava.RedirectUri = fmt.Sprintf("http://localhost:%d/callback", freePort(t))
+
+	// authorize a new athlete, the browser redirect is a request to the callback
+	done := make(chan struct{})
+	go func() {
+		runCmd(newTokenCmd)
+		close(done)
+	}()
+	callback := conf.Strava.RedirectUri + "?code=" + srv.Code()
+	var resp *http.Response
+	var err error
+	for i := 0; i < 100; i++ {
+		if resp, err = http.Get(callback); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Unable to request the callback. %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Incorrect callback status. Found(%d), Expected(%d)", resp.StatusCode, http.StatusOK)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("newtoken did not finish after the authorization")
+	}
+
+	athlete, err := store.SelectStravaAthleteById(7)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if athlete.FirstName != "Ann" || athlete.LastName != "Lee" {
+		t.Errorf("Incorrect athlete. Found(%s %s), Expected(%s)", athlete.FirstName, athlete.LastName, "Ann Lee")
+	}
+	auth, err := store.SelectStravaAuthById(7)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if auth.AccessToken != srv.Token().AccessToken {
+		t.Errorf("Incorrect access token. Found(%s), Expected(%s)", auth.AccessToken, srv.Token().AccessToken)
+	}
+
+	// refresh the tokens of the athlete
+	rootFlags.athlete = "ann"
+	runCmd(newTokenCmd)
+	if srv.Refreshes() != 1 {
+		t.Errorf("Incorrect number of token refreshes. Found(%d), Expected(%d)", srv.Refreshes(), 1)
+	}
+	auth, err = store.SelectStravaAuthById(7)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if token := srv.Token(); auth.AccessToken != token.AccessToken || auth.RefreshToken != token.RefreshToken {
+		t.Errorf("Incorrect refreshed tokens. Found(%s, %s), Expected(%s, %s)",
+			auth.AccessToken, auth.RefreshToken, token.AccessToken, token.RefreshToken)
+	}
+}
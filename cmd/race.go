@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"github.com/ddominguez/run-david-run/db"
+	"github.com/ddominguez/run-david-run/page"
+	"github.com/ddominguez/run-david-run/utils"
+)
+
+// newRaceData returns the race page data for a race activity of a site
+func newRaceData(a db.RaceActivity, d raceDisplay, site athleteSite) (page.RaceData, error) {
+	u := d.units
+	startDate, err := a.StartDateFormatted()
+	if err != nil {
+		return page.RaceData{}, err
+	}
+
+	data := page.RaceData{
+		Site:      site.page(),
+		Name:      a.Name,
+		StartDate: startDate,
+		Distance:  u.Distance(a.Distance),
+		PaceLabel: "Pace",
+		Pace:      u.PaceOrSpeed(a.SportType, a.Distance, d.raceTimeBasis.Seconds(a.ElapsedTime, a.MovingTime)),
+		Time:      utils.TimeFormatted(a.ElapsedTime),
+		Units:     u.Name,
+	}
+	if !utils.UsesPace(a.SportType) {
+		data.PaceLabel = "Speed"
+	}
+
+	streams, err := store.SelectRaceStreams(a.StravaId)
+	if err != nil {
+		return data, err
+	}
+	if err := setRaceMap(&data, a, streams, u); err != nil {
+		return data, err
+	}
+
+	splitKind := db.SplitStandard
+	if u.IsMetric() {
+		splitKind = db.SplitMetric
+	}
+	splits, err := store.SelectRaceSplits(a.StravaId, splitKind)
+	if err != nil {
+		return data, err
+	}
+	for _, s := range splits {
+		data.Splits = append(data.Splits, page.SplitData{
+			Split:     s.Split,
+			Distance:  u.Distance(s.Distance),
+			Pace:      u.PaceOrSpeed(a.SportType, s.Distance, d.splitTimeBasis.Seconds(s.ElapsedTime, s.MovingTime)),
+			Time:      utils.TimeFormatted(s.ElapsedTime),
+			Elevation: u.ElevationChange(s.ElevationDifference),
+		})
+	}
+
+	efforts, err := store.SelectRaceBestEfforts(a.StravaId)
+	if err != nil {
+		return data, err
+	}
+	for _, e := range efforts {
+		data.BestEfforts = append(data.BestEfforts, page.BestEffortData{
+			Name:   e.Name,
+			Time:   utils.TimeFormatted(e.ElapsedTime),
+			Pace:   u.Pace(e.Distance, e.ElapsedTime),
+			PrRank: e.PrRank,
+		})
+	}
+
+	data.Charts = newRaceCharts(streams, u, a.SportType)
+	return data, nil
+}
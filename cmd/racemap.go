@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ddominguez/run-david-run/chart"
+	"github.com/ddominguez/run-david-run/config"
+	"github.com/ddominguez/run-david-run/db"
+	"github.com/ddominguez/run-david-run/page"
+	"github.com/ddominguez/run-david-run/polyline"
+	"github.com/ddominguez/run-david-run/utils"
+	"github.com/spf13/cobra"
+)
+
+// Map backends of the race page
+const (
+	mapSVG    = "svg"
+	mapMapbox = "mapbox"
+)
+
+var mapFlags struct {
+	backend      string
+	privacyZones []string
+	hideEnds     float64
+}
+
+// mapPrivacy is the privacy of the race maps, set by checkMapConfig
+var mapPrivacy polyline.Privacy
+
+// addMapFlags adds the flags that override how race maps are rendered
+func addMapFlags(cmd *cobra.Command) {
+	f := cmd.Flags()
+	f.StringVar(&mapFlags.backend, "map", "",
+		"race map backend: svg renders the route without third party services, "+
+			"mapbox links a static mapbox image and needs a mapbox access token (map.backend)")
+	f.StringArrayVar(&mapFlags.privacyZones, "privacy-zone", nil,
+		"hide the start and end of routes inside a circle, as lat,lng,radius in meters, "+
+			"e.g. 40.7128,-74.0060,500 (map.privacy_zones)")
+	f.Float64Var(&mapFlags.hideEnds, "hide-ends", 0,
+		"hide the first and last meters of routes (map.hide_ends)")
+}
+
+// applyMapFlags overrides the settings of the map flags set on the command line
+func applyMapFlags(cmd *cobra.Command, c *config.Config) {
+	flags := cmd.Flags()
+	if flags.Changed("map") {
+		c.Map.Backend = mapFlags.backend
+	}
+	if flags.Changed("privacy-zone") {
+		c.Map.PrivacyZones = mapFlags.privacyZones
+	}
+	if flags.Changed("hide-ends") {
+		c.Map.HideEnds = mapFlags.hideEnds
+	}
+}
+
+// parsePrivacyZone parses a lat,lng,radius privacy zone
+func parsePrivacyZone(v string) (polyline.Zone, error) {
+	var z polyline.Zone
+	parts := strings.Split(v, ",")
+	if len(parts) != 3 {
+		return z, fmt.Errorf("invalid privacy zone %q, expected lat,lng,radius", v)
+	}
+	var values [3]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return z, fmt.Errorf("invalid privacy zone %q, expected lat,lng,radius", v)
+		}
+		values[i] = f
+	}
+	z.Center = polyline.Point{Lat: values[0], Lng: values[1]}
+	z.Radius = values[2]
+	if math.Abs(z.Center.Lat) > 90 || math.Abs(z.Center.Lng) > 180 || z.Radius <= 0 {
+		return z, fmt.Errorf("invalid privacy zone %q, lat, lng or radius out of range", v)
+	}
+	return z, nil
+}
+
+// checkMapConfig returns an error for an unknown map backend or an invalid
+// privacy zone, and sets the privacy of the race maps
+func checkMapConfig() error {
+	switch conf.Map.Backend {
+	case mapSVG, mapMapbox:
+	default:
+		return fmt.Errorf("invalid map backend %q, expected %s or %s", conf.Map.Backend, mapSVG, mapMapbox)
+	}
+
+	p := polyline.Privacy{HideEnds: conf.Map.HideEnds}
+	for _, v := range conf.Map.PrivacyZones {
+		z, err := parsePrivacyZone(v)
+		if err != nil {
+			return err
+		}
+		p.Zones = append(p.Zones, z)
+	}
+	mapPrivacy = p
+	return nil
+}
+
+// routeTolerance in meters simplifies high resolution routes to keep maps small
+const routeTolerance = 2
+
+// routePoints returns the high resolution route of the streams when
+// saved, or the route of the summary polyline.
+func routePoints(a db.RaceActivity, s db.RaceStreams) ([]polyline.Point, error) {
+	if len(s.LatLng) > 1 {
+		points := make([]polyline.Point, len(s.LatLng))
+		for i, p := range s.LatLng {
+			points[i] = polyline.Point{Lat: p[0], Lng: p[1]}
+		}
+		return points, nil
+	}
+	return polyline.Decode(a.Polyline)
+}
+
+// setMapboxMap sets the mapbox image of the race page. The summary
+// polyline is published as is unless part of the route is hidden.
+func setMapboxMap(data *page.RaceData, a db.RaceActivity) error {
+	encoded := a.Polyline
+	if !mapPrivacy.IsEmpty() {
+		points, err := polyline.Decode(a.Polyline)
+		if err != nil {
+			return fmt.Errorf("unable to decode the polyline of %d: %w", a.StravaId, err)
+		}
+		points, _ = mapPrivacy.Apply(points)
+		if len(points) < 2 {
+			return nil
+		}
+		encoded = polyline.Encode(points)
+	}
+
+	url, err := utils.MapboxURL(encoded, conf.Mapbox.AccessToken)
+	if err != nil {
+		return err
+	}
+	data.MapboxUrl = url
+	return nil
+}
+
+// setRaceMap sets the map of the race page with the selected backend and
+// without the parts of the route hidden by the privacy options. The svg
+// map is used when a mapbox url can't be created.
+func setRaceMap(data *page.RaceData, a db.RaceActivity, s db.RaceStreams, u utils.Units) error {
+	if conf.Map.Backend == mapMapbox {
+		err := setMapboxMap(data, a)
+		if err == nil {
+			return nil
+		}
+		fmt.Printf("%s, using the svg map\n", err)
+	}
+
+	points, err := routePoints(a, s)
+	if err != nil {
+		return fmt.Errorf("unable to decode the polyline of %d: %w", a.StravaId, err)
+	}
+	points, offset := mapPrivacy.Apply(points)
+	data.MapSVG = chart.Route{
+		Title:        a.Name,
+		Points:       polyline.Simplify(points, routeTolerance),
+		MarkerEvery:  u.DistanceMeters(),
+		MarkerOffset: offset,
+	}.SVG()
+	return nil
+}
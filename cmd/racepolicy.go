@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ddominguez/run-david-run/config"
+	"github.com/ddominguez/run-david-run/strava"
+	"github.com/spf13/cobra"
+)
+
+var racePolicyFlags struct {
+	sportTypes   []string
+	workoutTypes []uint
+	nameKeywords []string
+	includeIds   []int64
+	excludeIds   []int64
+}
+
+// addRacePolicyFlags adds the flags that override the races settings,
+// which configure the strava activities that are races
+func addRacePolicyFlags(cmd *cobra.Command) {
+	f := cmd.Flags()
+	f.StringSliceVar(&racePolicyFlags.sportTypes, "sport-type", nil,
+		"strava sport types that can be races, e.g. Run,TrailRun,VirtualRun,Ride (races.sport_types)")
+	f.UintSliceVar(&racePolicyFlags.workoutTypes, "workout-type", nil,
+		"strava workout types that mark a race, 1 is a run race and 11 a ride race (races.workout_types)")
+	f.StringSliceVar(&racePolicyFlags.nameKeywords, "name-keyword", nil,
+		"activities with a name containing a keyword are races, e.g. Marathon,Triathlon (races.name_keywords)")
+	f.Int64SliceVar(&racePolicyFlags.includeIds, "include-id", nil,
+		"strava activity ids that are always races (races.include_ids)")
+	f.Int64SliceVar(&racePolicyFlags.excludeIds, "exclude-id", nil,
+		"strava activity ids that are never races (races.exclude_ids)")
+}
+
+func activityIds(ids []int64) ([]uint64, error) {
+	res := make([]uint64, len(ids))
+	for i, id := range ids {
+		if id <= 0 {
+			return nil, fmt.Errorf("invalid strava activity id %d", id)
+		}
+		res[i] = uint64(id)
+	}
+	return res, nil
+}
+
+// applyRacePolicyFlags overrides the races settings with the race policy
+// flags set on the command line
+func applyRacePolicyFlags(cmd *cobra.Command, c *config.Config) error {
+	var err error
+	flags := cmd.Flags()
+	if flags.Changed("sport-type") {
+		c.Races.SportTypes = racePolicyFlags.sportTypes
+	}
+	if flags.Changed("name-keyword") {
+		c.Races.NameKeywords = racePolicyFlags.nameKeywords
+	}
+	if flags.Changed("workout-type") {
+		c.Races.WorkoutTypes = nil
+		for _, w := range racePolicyFlags.workoutTypes {
+			if w > math.MaxUint8 {
+				return fmt.Errorf("invalid strava workout type %d", w)
+			}
+			c.Races.WorkoutTypes = append(c.Races.WorkoutTypes, uint8(w))
+		}
+	}
+	if flags.Changed("include-id") {
+		if c.Races.IncludeIds, err = activityIds(racePolicyFlags.includeIds); err != nil {
+			return err
+		}
+	}
+	if flags.Changed("exclude-id") {
+		if c.Races.ExcludeIds, err = activityIds(racePolicyFlags.excludeIds); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// racePolicy returns the race policy of the races settings
+func racePolicy() strava.RacePolicy {
+	return strava.RacePolicy{
+		SportTypes:   conf.Races.SportTypes,
+		WorkoutTypes: conf.Races.WorkoutTypes,
+		NameKeywords: conf.Races.NameKeywords,
+		IncludeIds:   conf.Races.IncludeIds,
+		ExcludeIds:   conf.Races.ExcludeIds,
+	}
+}
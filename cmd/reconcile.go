@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ddominguez/run-david-run/db"
+	"github.com/ddominguez/run-david-run/strava"
+	"github.com/spf13/cobra"
+)
+
+const (
+	hiddenReasonDeleted = "deleted on strava"
+	hiddenReasonNotRace = "no longer a race on strava"
+)
+
+// reconcileReason returns why a stored race should be hidden,
+// or an empty string when it is still a race on strava.
+func reconcileReason(client *strava.Client, policy strava.RacePolicy, r db.RaceActivity) (string, error) {
+	a, err := strava.GetActivity(client, r.StravaId)
+	if err != nil {
+		if strava.IsNotFound(err) {
+			return hiddenReasonDeleted, nil
+		}
+		return "", err
+	}
+	if !policy.Match(a) {
+		return hiddenReasonNotRace, nil
+	}
+	return "", nil
+}
+
+var reconcileDryRun bool
+
+// reconcileAthlete checks the saved races of an authorized athlete on strava
+func reconcileAthlete(stravaAuth *db.StravaAuth, policy strava.RacePolicy) error {
+	client, err := newStravaClient(stravaAuth)
+	if err != nil {
+		return err
+	}
+
+	activities, err := store.AthleteRaceActivitiesWithHidden(stravaAuth.AthleteId)
+	if err != nil {
+		return err
+	}
+
+	var checked, hidden, restored int
+	defer func() {
+		if reconcileDryRun {
+			fmt.Println("-- dry run, no changes were saved")
+		}
+		fmt.Printf("-- %d of %d checked, %d hidden, %d restored\n", checked, len(activities), hidden, restored)
+		fmt.Println("-- strava api usage:", client.RateLimit())
+	}()
+
+	for _, r := range activities {
+		reason, err := reconcileReason(client, policy, r)
+		if err != nil {
+			return err
+		}
+		checked++
+
+		switch {
+		case reason != "" && !r.IsHidden():
+			fmt.Printf("hide    %d %s: %s\n", r.StravaId, r.Name, reason)
+			if !reconcileDryRun {
+				err = store.HideRaceActivity(r.StravaId, reason)
+			}
+			hidden++
+		case reason != "" && reason != r.HiddenReason:
+			fmt.Printf("update  %d %s: %s\n", r.StravaId, r.Name, reason)
+			if !reconcileDryRun {
+				err = store.HideRaceActivity(r.StravaId, reason)
+			}
+		case reason == "" && r.IsHidden():
+			fmt.Printf("restore %d %s\n", r.StravaId, r.Name)
+			if !reconcileDryRun {
+				err = store.UnhideRaceActivity(r.StravaId)
+			}
+			restored++
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var reconcileCmd = &cobra.Command{
+	Use:   "reconcile",
+	Short: "Hide saved races that were deleted or are no longer races on Strava",
+	Long: "reconcile will check every saved race activity on Strava.\n" +
+		"Races that were deleted or are no longer flagged as races are hidden\n" +
+		"from the site, and hidden races that are races again are restored.\n" +
+		"Every authorized athlete is checked, or only --athlete.\n" +
+		"Use --dry-run to only report the changes. Races are decided with the\n" +
+		"races settings of the config, the same as fetch, set them there rather\n" +
+		"than with the race policy flags so both commands agree.",
+	Run: func(cmd *cobra.Command, args []string) {
+		policy := racePolicy()
+		athletes, err := selectAuthorizedAthletes(cmd.Context())
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		for _, a := range athletes {
+			fmt.Printf("-- reconciling races of %s --\n", athleteName(a.athlete))
+			err := reconcileAthlete(a.auth, policy)
+			if err == nil {
+				continue
+			}
+			printStravaError("reconcile", err)
+			// other athletes can still be checked when one revoked access
+			if !strava.IsUnauthorized(err) {
+				return
+			}
+		}
+	},
+	Annotations: map[string]string{storeAnnotation: ""},
+}
+
+func init() {
+	reconcileCmd.Flags().BoolVar(&reconcileDryRun, "dry-run", false, "report changes without saving them")
+	addRacePolicyFlags(reconcileCmd)
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ddominguez/run-david-run/db"
+	"github.com/ddominguez/run-david-run/page"
+	"github.com/ddominguez/run-david-run/records"
+	"github.com/ddominguez/run-david-run/utils"
+)
+
+// recordsUrl returns the url of the personal records page
+func recordsUrl(site athleteSite) string {
+	if site.isGenerated {
+		return site.base + "records/"
+	}
+	return site.base + "records"
+}
+
+// raceUrl returns the url of the race page of a race activity
+func raceUrl(a db.RaceActivity, site athleteSite) (string, error) {
+	if !site.isGenerated {
+		return fmt.Sprintf("/activity/%d", a.StravaId), nil
+	}
+	year, err := a.RaceYear()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%d/%s/", site.base, year, a.NameSlugified()), nil
+}
+
+// newRecordData returns a personal record, or a race of its progression
+func newRecordData(a db.RaceActivity, c db.RaceCategory, u utils.Units, site athleteSite) (page.RecordData, error) {
+	url, err := raceUrl(a, site)
+	if err != nil {
+		return page.RecordData{}, err
+	}
+	date, err := a.StartDateShort()
+	if err != nil {
+		return page.RecordData{}, err
+	}
+	return page.RecordData{
+		Distance: c.Name,
+		Time:     utils.TimeFormatted(a.ElapsedTime),
+		Pace:     u.PaceOrSpeed(a.SportType, a.Distance, a.ElapsedTime),
+		Name:     a.Name,
+		Url:      url,
+		Date:     date,
+	}, nil
+}
+
+// newRecordsData returns the personal records page data of all activities of a site
+func newRecordsData(activities []db.RaceActivity, u utils.Units, site athleteSite) (page.RecordsData, error) {
+	data := page.RecordsData{Site: site.page()}
+	for _, rec := range records.Compute(activities).Records {
+		if n := len(data.Sports); n == 0 || data.Sports[n-1].Sport != db.SportName(rec.SportType) {
+			sport := page.SportRecordsData{Sport: db.SportName(rec.SportType), PaceLabel: "Pace"}
+			if !utils.UsesPace(rec.SportType) {
+				sport.PaceLabel = "Speed"
+			}
+			data.Sports = append(data.Sports, sport)
+		}
+
+		best, err := newRecordData(rec.Best, rec.Category, u, site)
+		if err != nil {
+			return data, err
+		}
+		// newest first, the best time is the last race of the progression
+		for i := len(rec.Progression) - 2; i >= 0; i-- {
+			p, err := newRecordData(rec.Progression[i], rec.Category, u, site)
+			if err != nil {
+				return data, err
+			}
+			best.Progression = append(best.Progression, p)
+		}
+
+		sport := &data.Sports[len(data.Sports)-1]
+		sport.Records = append(sport.Records, best)
+	}
+	return data, nil
+}
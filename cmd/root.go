@@ -1,12 +1,121 @@
 package cmd
 
 import (
+	"github.com/ddominguez/run-david-run/config"
+	"github.com/ddominguez/run-david-run/db"
 	"github.com/spf13/cobra"
 )
 
-var rootCmd = &cobra.Command{Use: "races"}
+// conf are the settings loaded before any command runs
+var conf config.Config
+
+// store is the database opened before any command that uses it runs
+var store *db.Store
+
+// storeAnnotation marks the commands that use the database, the mark of a
+// command applies to its subcommands
+const storeAnnotation = "store"
+
+var rootFlags struct {
+	config    string
+	db        string
+	dist      string
+	templates string
+	static    string
+	athlete   string
+}
+
+var rootCmd = &cobra.Command{
+	Use:           "races",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Long: "races saves Strava races and generates a site for them.\n" +
+		"Settings are loaded from --config, or races.yaml, races.yml or races.toml\n" +
+		"when found, then from environment variables and then from flags.\n" +
+		"Use --athlete with a strava id, first name or full name, e.g. david-d,\n" +
+		"to run a command for one athlete, by default commands run for every athlete.",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		conf, err = loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		if !usesStore(cmd) {
+			return nil
+		}
+		store, err = db.Open(conf.DB)
+		if err != nil {
+			return err
+		}
+		if isMigrateCmd(cmd) {
+			return nil
+		}
+		return store.CheckVersionContext(cmd.Context())
+	},
+	PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+		if !usesStore(cmd) {
+			return nil
+		}
+		return store.Close()
+	},
+}
+
+// usesStore returns true when cmd or a parent is marked with storeAnnotation.
+// The help and completion commands never open the database.
+func usesStore(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		switch c.Name() {
+		case "help", "completion":
+			return false
+		}
+		if _, ok := c.Annotations[storeAnnotation]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// loadConfig returns the settings of the config file and the environment
+// overridden by the root flags and the flags of cmd set on the command line
+func loadConfig(cmd *cobra.Command) (config.Config, error) {
+	c, err := config.Load(rootFlags.config)
+	if err != nil {
+		return c, err
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("db") {
+		c.DB = rootFlags.db
+	}
+	if flags.Changed("dist") {
+		c.Dist = rootFlags.dist
+	}
+	if flags.Changed("templates") {
+		c.Templates = rootFlags.templates
+	}
+	if flags.Changed("static") {
+		c.Static = rootFlags.static
+	}
+	applyUnitsFlags(cmd, &c)
+	applyMapFlags(cmd, &c)
+	if err := applyRacePolicyFlags(cmd, &c); err != nil {
+		return c, err
+	}
+	return c, c.Validate()
+}
 
 func Execute() error {
-	rootCmd.AddCommand(newTokenCmd, fetchCmd, genHtmlCmd, serverCmd)
+	rootCmd.AddCommand(newTokenCmd, fetchCmd, reconcileCmd, categoryCmd, genHtmlCmd, serverCmd, migrateCmd)
 	return rootCmd.Execute()
 }
+
+func init() {
+	def := config.Default()
+	f := rootCmd.PersistentFlags()
+	f.StringVar(&rootFlags.config, "config", "", "yaml or toml config file")
+	f.StringVar(&rootFlags.db, "db", def.DB, "sqlite database file")
+	f.StringVar(&rootFlags.dist, "dist", def.Dist, "directory of the generated html")
+	f.StringVar(&rootFlags.templates, "templates", def.Templates, "directory of the html templates")
+	f.StringVar(&rootFlags.static, "static", def.Static, "directory of the css and other static files")
+	f.StringVar(&rootFlags.athlete, "athlete", "", "strava id, first name or full name of the athlete, e.g. david-d")
+}
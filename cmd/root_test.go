@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ddominguez/run-david-run/config"
+	"github.com/ddominguez/run-david-run/db"
+	"github.com/ddominguez/run-david-run/strava/stravatest"
+	"github.com/spf13/cobra"
+)
+
+// newTestEnv points the commands at a fake strava server and a migrated
+// temporary database. The settings and flags are restored after the test.
+func newTestEnv(t *testing.T) *stravatest.Server {
+	t.Helper()
+	srv := stravatest.NewServer()
+	t.Cleanup(srv.Close)
+
+	prevConf, prevStore, prevRoot, prevFetch := conf, store, rootFlags, fetchFlags
+	t.Cleanup(func() {
+		conf, store, rootFlags, fetchFlags = prevConf, prevStore, prevRoot, prevFetch
+	})
+
+	conf = config.Default()
+	conf.DB = filepath.Join(t.TempDir(), "strava.db")
+	conf.Strava.ClientId = "testid"
+	conf.Strava.ClientSecret = "testsecret"
+	conf.Strava.APIURL = srv.APIURL()
+	conf.Strava.OAuthURL = srv.OAuthURL()
+
+	s, err := db.Open(conf.DB)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	if _, err := s.MigrateUp(); err != nil {
+		t.Fatalf("Unable to migrate. %s", err)
+	}
+	store = s
+	return srv
+}
+
+// authorizeAthlete saves the athlete and the current token of the fake server
+func authorizeAthlete(t *testing.T, srv *stravatest.Server, a db.StravaAthlete) {
+	t.Helper()
+	if err := store.InsertStravaAthelete(a); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	token := srv.Token()
+	err := store.InsertStravaAuth(db.StravaAuth{
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		ExpiresAt:    token.ExpiresAt,
+		AthleteId:    a.StravaId,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+}
+
+// runCmd runs a command without arguments like the root command would
+func runCmd(cmd *cobra.Command) {
+	cmd.SetContext(context.Background())
+	cmd.Run(cmd, nil)
+}
+
+func TestHelpWithoutDatabase(t *testing.T) {
+	prevConf, prevStore, prevRoot := conf, store, rootFlags
+	t.Cleanup(func() {
+		conf, store, rootFlags = prevConf, prevStore, prevRoot
+		rootCmd.SetArgs(nil)
+		rootCmd.SetOut(nil)
+	})
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	rootCmd.SetArgs([]string{"help"})
+	rootCmd.SetOut(io.Discard)
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Incorrect files after help. Found(%s), Expected(none)", entries[0].Name())
+	}
+}
+
+func TestUsesStore(t *testing.T) {
+	completion := &cobra.Command{Use: "completion"}
+	bash := &cobra.Command{Use: "bash"}
+	completion.AddCommand(bash)
+
+	tests := []struct {
+		cmd       *cobra.Command
+		usesStore bool
+	}{
+		{fetchCmd, true},
+		{migrateStatusCmd, true},
+		{rootCmd, false},
+		{completion, false},
+		{bash, false},
+	}
+	for _, test := range tests {
+		if usesStore(test.cmd) != test.usesStore {
+			t.Errorf("Incorrect usesStore of %s. Found(%v), Expected(%v)", test.cmd.Name(), usesStore(test.cmd), test.usesStore)
+		}
+	}
+}
@@ -3,72 +3,180 @@ package cmd
 import (
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 
 	"github.com/ddominguez/run-david-run/db"
+	"github.com/ddominguez/run-david-run/events"
 	"github.com/ddominguez/run-david-run/page"
-	"github.com/ddominguez/run-david-run/utils"
+	"github.com/ddominguez/run-david-run/records"
+	"github.com/ddominguez/run-david-run/stats"
 	"github.com/spf13/cobra"
 )
 
-func handleIndex(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/" {
+// siteHandlerFunc serves a page of the site of an athlete
+type siteHandlerFunc func(w http.ResponseWriter, r *http.Request, site athleteSite)
+
+// serveSite returns a handler of the site of the athlete in the url path,
+// or of the site at the root
+func serveSite(sites []athleteSite, h siteHandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !sites[0].club {
+			h(w, r, sites[0])
+			return
+		}
+		i := slices.IndexFunc(sites, func(s athleteSite) bool { return s.slug == r.PathValue("athlete") })
+		if i < 0 {
+			http.NotFound(w, r)
+			return
+		}
+		h(w, r, sites[i])
+	}
+}
+
+func handleIndex(w http.ResponseWriter, r *http.Request, site athleteSite) {
+	if r.URL.Path != site.base {
 		http.NotFound(w, r)
 		return
 	}
 
-	activities, err := db.AllRaceActivities()
+	activities, err := store.AthleteRaceActivitiesContext(r.Context(), site.athlete.StravaId)
 	if err != nil {
 		fmt.Println(err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
 
-	data := struct {
-		Activities  []db.RaceActivity
-		IsGenerated bool
-	}{
-		Activities:  activities,
-		IsGenerated: false,
+	q := r.URL.Query()
+	data := newIndexData(activities, q.Get("sport"), q.Get("category"), site)
+
+	tmpl := page.New(conf.TemplateFiles("base.html", "index.html"))
+	err = tmpl.Execute(w, "base", data)
+	if err != nil {
+		fmt.Println("failed to execute to templates", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// handleActivity serves the race page of a race of any of the sites
+func handleActivity(sites []athleteSite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 0)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		activity, err := store.SelectRaceActivityByIdContext(r.Context(), id)
+		if err != nil {
+			fmt.Println(err)
+			http.NotFound(w, r)
+			return
+		}
+		i := slices.IndexFunc(sites, func(s athleteSite) bool { return s.athlete.StravaId == activity.AthleteId })
+		if i < 0 || activity.IsHidden() {
+			http.NotFound(w, r)
+			return
+		}
+		serveActivity(w, r, activity, sites[i], sites)
+	}
+}
+
+// serveActivity serves the race page of an activity of a site, sites are
+// all sites to link the event of the race
+func serveActivity(w http.ResponseWriter, r *http.Request, activity db.RaceActivity, site athleteSite, sites []athleteSite) {
+	d := newRaceDisplay()
+	d.units = requestUnits(w, r)
+	data, err := newRaceData(activity, d, site)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
 	}
+	data.UnitLinks = unitLinks(r, d.units)
 
-	tmpl := page.New([]string{"templates/base.html", "templates/index.html"})
+	activities, err := siteActivities(r.Context(), sites)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	data.SetPR = records.Compute(activities[site.athlete.StravaId]).SetPR(activity.StravaId)
+	evs, err := clubEvents(sites, activities)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	data.Event = eventLinks(evs, false)[activity.StravaId]
+
+	tmpl := page.New(conf.TemplateFiles("base.html", "race.html"))
 	err = tmpl.Execute(w, "base", data)
 	if err != nil {
 		fmt.Println("failed to execute to templates", err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 	}
 }
-func handleActivity(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.ParseUint(r.PathValue("id"), 10, 0)
+
+func handleRecords(w http.ResponseWriter, r *http.Request, site athleteSite) {
+	activities, err := store.AthleteRaceActivitiesContext(r.Context(), site.athlete.StravaId)
 	if err != nil {
-		http.NotFound(w, r)
+		fmt.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
 
-	activity, err := db.SelectRaceActivityById(id)
+	units := requestUnits(w, r)
+	data, err := newRecordsData(activities, units, site)
 	if err != nil {
 		fmt.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	data.UnitLinks = unitLinks(r, units)
+
+	tmpl := page.New(conf.TemplateFiles("base.html", "records.html"))
+	err = tmpl.Execute(w, "base", data)
+	if err != nil {
+		fmt.Println("failed to execute to templates", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func handleYear(w http.ResponseWriter, r *http.Request, site athleteSite) {
+	year, err := strconv.Atoi(r.PathValue("year"))
+	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
 
-	startDate, err := activity.StartDateFormatted()
+	activities, err := store.AthleteRaceActivitiesContext(r.Context(), site.athlete.StravaId)
 	if err != nil {
+		fmt.Println(err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
+	years, err := stats.ByYear(activities)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	i := slices.IndexFunc(years, func(y stats.Year) bool { return y.Year == year })
+	if i < 0 {
+		http.NotFound(w, r)
+		return
+	}
 
-	data := page.RaceData{
-		Name:      activity.Name,
-		StartDate: startDate,
-		Distance:  utils.ActivityDistance(activity.Distance),
-		Pace:      utils.ActivityPace(activity.Distance, activity.ElapsedTime),
-		Time:      utils.TimeFormatted(activity.ElapsedTime),
-		MapboxUrl: utils.MapboxURL(activity.Polyline),
+	units := requestUnits(w, r)
+	data, err := newYearData(years[i], years, units, site)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
 	}
+	data.UnitLinks = unitLinks(r, units)
 
-	tmpl := page.New([]string{"templates/base.html", "templates/race.html"})
+	tmpl := page.New(conf.TemplateFiles("base.html", "year.html"))
 	err = tmpl.Execute(w, "base", data)
 	if err != nil {
 		fmt.Println("failed to execute to templates", err)
@@ -76,16 +184,107 @@ func handleActivity(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func startServer() {
-	http.HandleFunc("/", handleIndex)
-	http.HandleFunc("/activity/{id}", handleActivity)
+// handleClub serves the club page listing the races of every site
+func handleClub(sites []athleteSite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		activities, err := siteActivities(r.Context(), sites)
+		if err != nil {
+			fmt.Println(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		evs, err := clubEvents(sites, activities)
+		if err != nil {
+			fmt.Println(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		data, err := newClubData(sites, activities, evs)
+		if err != nil {
+			fmt.Println(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		tmpl := page.New(conf.TemplateFiles("base.html", "club.html"))
+		err = tmpl.Execute(w, "base", data)
+		if err != nil {
+			fmt.Println("failed to execute to templates", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	}
+}
+
+// handleEvent serves the page of an event run by athletes of the sites
+func handleEvent(sites []athleteSite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		activities, err := siteActivities(r.Context(), sites)
+		if err != nil {
+			fmt.Println(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		evs, err := clubEvents(sites, activities)
+		if err != nil {
+			fmt.Println(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		i := slices.IndexFunc(evs, func(e events.Event) bool { return e.Key == r.PathValue("key") })
+		if i < 0 {
+			http.NotFound(w, r)
+			return
+		}
 
-	fs := http.FileServer(http.Dir("./static"))
+		d := newRaceDisplay()
+		d.units = requestUnits(w, r)
+		data, err := newEventData(evs[i], sites, d)
+		if err != nil {
+			fmt.Println(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		data.UnitLinks = unitLinks(r, d.units)
+
+		tmpl := page.New(conf.TemplateFiles("base.html", "event.html"))
+		err = tmpl.Execute(w, "base", data)
+		if err != nil {
+			fmt.Println("failed to execute to templates", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	}
+}
+
+// startServer serves the site of a single athlete at the root, or the club
+// page at the root, every site under /athlete/<slug>/ and the events run
+// by several athletes under /event/<key>. With a club and --athlete only
+// the site of the athlete is served and the root redirects to it.
+func startServer(sites []athleteSite, club bool) {
+	prefix := ""
+	if sites[0].club {
+		prefix = "/athlete/{athlete}"
+	}
+	if club {
+		http.HandleFunc("/", handleClub(sites))
+		http.HandleFunc("/event/{key}", handleEvent(sites))
+	} else if sites[0].club {
+		http.Handle("/{$}", http.RedirectHandler(sites[0].base, http.StatusFound))
+	}
+	http.HandleFunc(prefix+"/", serveSite(sites, handleIndex))
+	http.HandleFunc(prefix+"/records", serveSite(sites, handleRecords))
+	http.HandleFunc(prefix+"/year/{year}", serveSite(sites, handleYear))
+	http.HandleFunc("/activity/{id}", handleActivity(sites))
+
+	fs := http.FileServer(http.Dir(conf.Static))
 	http.Handle("/static/", http.StripPrefix("/static/", fs))
 
-	port := "8080"
-	fmt.Printf("Listening on http://localhost:%s\n", port)
-	err := http.ListenAndServe(fmt.Sprintf(":%s", port), nil)
+	fmt.Printf("Listening on http://localhost:%d\n", conf.Server.Port)
+	err := http.ListenAndServe(fmt.Sprintf(":%d", conf.Server.Port), nil)
 	if err != nil {
 		fmt.Println(err)
 	}
@@ -94,8 +293,36 @@ func startServer() {
 var serverCmd = &cobra.Command{
 	Use:   "server",
 	Short: "http server for saved race activities",
-	Long:  "server will start an http server for saved race activities.",
+	Long: "server will start an http server for saved race activities.\n" +
+		"With several athletes every athlete has a site under /athlete/<name>/\n" +
+		"and the index page lists the races of the club. Races run by several\n" +
+		"athletes are grouped into event pages under /event/<key>. Use --athlete to\n" +
+		"serve the site of one athlete only. Athletes are loaded at startup.",
 	Run: func(cmd *cobra.Command, args []string) {
-		startServer()
+		if err := checkMapConfig(); err != nil {
+			fmt.Println(err)
+			return
+		}
+		selected, sites, err := selectSites(cmd.Context(), false)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if len(sites) == 0 {
+			fmt.Println("there are no athletes, run newtoken to authorize one")
+			return
+		}
+		club := len(sites) > 1 && len(selected) == len(sites)
+		if !club {
+			// the club page is not served, so the site does not link to it
+			selected[0].clubUrl = ""
+		}
+		startServer(selected, club)
 	},
+	Annotations: map[string]string{storeAnnotation: ""},
+}
+
+func init() {
+	addMapFlags(serverCmd)
+	addUnitsFlags(serverCmd)
 }
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ddominguez/run-david-run/db"
+	"github.com/ddominguez/run-david-run/strava"
+)
+
+func getStravaClientCreds() (string, string, error) {
+	if conf.Strava.ClientId == "" {
+		return "", "", fmt.Errorf("missing strava client id")
+	}
+	if conf.Strava.ClientSecret == "" {
+		return "", "", fmt.Errorf("missing strava client secret")
+	}
+	return conf.Strava.ClientId, conf.Strava.ClientSecret, nil
+}
+
+// stravaOptions returns the strava api options of the config. The api and
+// oauth urls point the app at another server, e.g. a local fake Strava server.
+func stravaOptions() []strava.Option {
+	var opts []strava.Option
+	if u := conf.Strava.APIURL; u != "" {
+		opts = append(opts, strava.WithAPIURL(u))
+	}
+	if u := conf.Strava.OAuthURL; u != "" {
+		opts = append(opts, strava.WithOAuthURL(u))
+	}
+	return opts
+}
+
+// newStravaAuthorization returns the strava oauth configuration for this app
+func newStravaAuthorization() (*strava.Authorization, error) {
+	clientId, clientSecret, err := getStravaClientCreds()
+	if err != nil {
+		return nil, err
+	}
+	oauth := &strava.Authorization{
+		ClientId:     clientId,
+		ClientSecret: clientSecret,
+		RedirectUri:  conf.Strava.RedirectUri,
+		Scope:        "activity:read_all",
+	}
+	oauth.Configure(stravaOptions()...)
+	return oauth, nil
+}
+
+// newStravaClient returns a strava client for the stored auth user.
+// Expired access tokens are refreshed and saved before they are used.
+func newStravaClient(sa *db.StravaAuth) (*strava.Client, error) {
+	oauth, err := newStravaAuthorization()
+	if err != nil {
+		return nil, err
+	}
+
+	token := strava.Token{
+		AccessToken:  sa.AccessToken,
+		RefreshToken: sa.RefreshToken,
+		ExpiresAt:    sa.ExpiresAt,
+	}
+	ts := strava.NewTokenSource(oauth, token, func(t strava.Token) error {
+		fmt.Println("-- refreshed strava access token")
+		return store.UpdateStravaAuth(db.StravaAuth{
+			AccessToken:  t.AccessToken,
+			RefreshToken: t.RefreshToken,
+			ExpiresAt:    t.ExpiresAt,
+			AthleteId:    sa.AthleteId,
+		})
+	})
+
+	return strava.NewTokenClient(ts, stravaOptions()...), nil
+}
+
+// printStravaError prints a strava api error with a hint for errors
+// that need action before the command name is run again
+func printStravaError(name string, err error) {
+	switch {
+	case errors.Is(err, strava.ErrDailyRateLimit):
+		fmt.Printf("-- strava daily rate limit reached, run %s again after the limit resets --\n", name)
+	case strava.IsUnauthorized(err):
+		fmt.Println("-- strava authorization is invalid or was revoked, run newtoken to authorize again --")
+	}
+	fmt.Println(err)
+}
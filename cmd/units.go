@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/ddominguez/run-david-run/config"
+	"github.com/ddominguez/run-david-run/page"
+	"github.com/ddominguez/run-david-run/utils"
+	"github.com/spf13/cobra"
+)
+
+// unitsCookie remembers the units selected on the server
+const unitsCookie = "units"
+
+var unitsFlags struct {
+	name           string
+	raceTimeBasis  string
+	splitTimeBasis string
+}
+
+// addUnitsFlags adds the flags that override the units of the race pages
+// and the time their paces are computed with
+func addUnitsFlags(cmd *cobra.Command) {
+	f := cmd.Flags()
+	f.StringVar(&unitsFlags.name, "units", "",
+		"units of distances, paces and elevations: mi or km (units)")
+	f.StringVar(&unitsFlags.raceTimeBasis, "race-pace-time", "",
+		"time used for the pace or speed of a race: elapsed or moving (race_pace_time)")
+	f.StringVar(&unitsFlags.splitTimeBasis, "split-pace-time", "",
+		"time used for the pace or speed of race splits: elapsed or moving (split_pace_time)")
+}
+
+// applyUnitsFlags overrides the settings of the units flags set on the
+// command line
+func applyUnitsFlags(cmd *cobra.Command, c *config.Config) {
+	flags := cmd.Flags()
+	if flags.Changed("units") {
+		c.Units = unitsFlags.name
+	}
+	if flags.Changed("race-pace-time") {
+		c.RacePaceTime = unitsFlags.raceTimeBasis
+	}
+	if flags.Changed("split-pace-time") {
+		c.SplitPaceTime = unitsFlags.splitTimeBasis
+	}
+}
+
+// raceDisplay selects how the numbers of a race page are displayed
+type raceDisplay struct {
+	units          utils.Units
+	raceTimeBasis  utils.TimeBasis
+	splitTimeBasis utils.TimeBasis
+}
+
+// newRaceDisplay returns the race display of the settings, which are
+// validated when they are loaded
+func newRaceDisplay() raceDisplay {
+	u, _ := utils.ParseUnits(conf.Units)
+	race, _ := utils.ParseTimeBasis(conf.RacePaceTime)
+	split, _ := utils.ParseTimeBasis(conf.SplitPaceTime)
+	return raceDisplay{units: u, raceTimeBasis: race, splitTimeBasis: split}
+}
+
+// requestUnits returns the units of the units query parameter and saves
+// them in a cookie, the units of the cookie or the units setting.
+func requestUnits(w http.ResponseWriter, r *http.Request) utils.Units {
+	if u, err := utils.ParseUnits(r.URL.Query().Get("units")); err == nil {
+		http.SetCookie(w, &http.Cookie{Name: unitsCookie, Value: u.Name, Path: "/", MaxAge: 365 * 24 * 3600})
+		return u
+	}
+	if c, err := r.Cookie(unitsCookie); err == nil {
+		if u, err := utils.ParseUnits(c.Value); err == nil {
+			return u
+		}
+	}
+	return newRaceDisplay().units
+}
+
+// unitLinks returns the links that switch the units of the requested page
+func unitLinks(r *http.Request, selected utils.Units) []page.Filter {
+	var res []page.Filter
+	for _, u := range []utils.Units{utils.Imperial, utils.Metric} {
+		q := url.Values{"units": {u.Name}}
+		res = append(res, page.Filter{
+			Key:    u.Name,
+			Name:   u.Name,
+			Url:    r.URL.Path + "?" + q.Encode(),
+			Active: u == selected,
+		})
+	}
+	return res
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ddominguez/run-david-run/db"
+	"github.com/ddominguez/run-david-run/page"
+	"github.com/ddominguez/run-david-run/stats"
+	"github.com/ddominguez/run-david-run/utils"
+)
+
+// yearUrl returns the url of the year in review page of a year
+func yearUrl(year int, site athleteSite) string {
+	if site.isGenerated {
+		return fmt.Sprintf("%s%d/", site.base, year)
+	}
+	return fmt.Sprintf("%syear/%d", site.base, year)
+}
+
+// newRaceLink returns a link to the race page of a race activity
+func newRaceLink(a db.RaceActivity, site athleteSite) (page.RaceLink, error) {
+	url, err := raceUrl(a, site)
+	if err != nil {
+		return page.RaceLink{}, err
+	}
+	date, err := a.StartDateShort()
+	if err != nil {
+		return page.RaceLink{}, err
+	}
+	return page.RaceLink{Name: a.Name, Url: url, Date: date}, nil
+}
+
+// newYearData returns the year in review page data of y. years are
+// all years with races.
+func newYearData(y stats.Year, years []stats.Year, u utils.Units, site athleteSite) (page.YearData, error) {
+	data := page.YearData{
+		Site:     site.page(),
+		Year:     y.Year,
+		Count:    len(y.Races),
+		Distance: u.Distance(y.Distance),
+		Time:     utils.TimeFormatted(y.ElapsedTime),
+	}
+
+	if y.HasFastest {
+		link, err := newRaceLink(y.Fastest, site)
+		if err != nil {
+			return data, err
+		}
+		data.Fastest = link
+		data.FastestPace = u.Pace(y.Fastest.Distance, y.Fastest.ElapsedTime)
+	}
+
+	for _, c := range y.Mix {
+		name := c.Category.Name
+		if name == "" {
+			name = "Other"
+		}
+		data.Mix = append(data.Mix, page.MixData{
+			Name:    name,
+			Count:   c.Count,
+			Percent: c.Count * 100 / len(y.Races),
+		})
+	}
+
+	for _, m := range y.Months {
+		month := page.MonthData{Name: m.Month.String()}
+		for _, a := range m.Races {
+			link, err := newRaceLink(a, site)
+			if err != nil {
+				return data, err
+			}
+			month.Races = append(month.Races, link)
+		}
+		data.Months = append(data.Months, month)
+	}
+
+	for _, other := range years {
+		data.Years = append(data.Years, page.Filter{
+			Key:    fmt.Sprintf("%d", other.Year),
+			Name:   fmt.Sprintf("%d", other.Year),
+			Url:    yearUrl(other.Year, site),
+			Active: other.Year == y.Year,
+		})
+	}
+	return data, nil
+}
@@ -0,0 +1,386 @@
+// Package config loads the settings of the app from a yaml or toml file,
+// environment variables and command line flags.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/ddominguez/run-david-run/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFiles are the config files loaded when no file is given,
+// the first one found is used.
+var DefaultFiles = []string{"races.yaml", "races.yml", "races.toml"}
+
+// Config are the settings of the app
+type Config struct {
+	// DB is the sqlite database file
+	DB string `yaml:"db" toml:"db"`
+	// Dist is the directory of the generated html
+	Dist string `yaml:"dist" toml:"dist"`
+	// Templates is the directory of the html templates
+	Templates string `yaml:"templates" toml:"templates"`
+	// Static is the directory of the css and other static files
+	Static string `yaml:"static" toml:"static"`
+	// Club is the name of the club page listing the races of every athlete
+	Club string `yaml:"club" toml:"club"`
+	// Units are the units of the race pages, mi or km
+	Units string `yaml:"units" toml:"units"`
+	// RacePaceTime and SplitPaceTime are the times the paces of races and
+	// splits are computed with, elapsed or moving
+	RacePaceTime  string `yaml:"race_pace_time" toml:"race_pace_time"`
+	SplitPaceTime string `yaml:"split_pace_time" toml:"split_pace_time"`
+
+	Server       Server `yaml:"server" toml:"server"`
+	Races        Races  `yaml:"races" toml:"races"`
+	Strava       Strava `yaml:"strava" toml:"strava"`
+	Map          Map    `yaml:"map" toml:"map"`
+	Mapbox       Mapbox `yaml:"mapbox" toml:"mapbox"`
+	MaxHeartrate int    `yaml:"max_heartrate" toml:"max_heartrate"`
+}
+
+// Server are the settings of the server command
+type Server struct {
+	Port int `yaml:"port" toml:"port"`
+}
+
+// Races decide which strava activities are races. fetch saves the races
+// and reconcile hides saved races that no longer are, so both use them.
+type Races struct {
+	// SportTypes can be races, empty allows every sport type
+	SportTypes []string `yaml:"sport_types" toml:"sport_types"`
+	// WorkoutTypes mark a race, 1 is a run race and 11 a ride race
+	WorkoutTypes []uint8 `yaml:"workout_types" toml:"workout_types"`
+	// NameKeywords mark a race when the activity name contains one
+	NameKeywords []string `yaml:"name_keywords" toml:"name_keywords"`
+	// IncludeIds are strava activity ids that are always races
+	IncludeIds []uint64 `yaml:"include_ids" toml:"include_ids"`
+	// ExcludeIds are strava activity ids that are never races
+	ExcludeIds []uint64 `yaml:"exclude_ids" toml:"exclude_ids"`
+}
+
+// Strava are the strava api settings
+type Strava struct {
+	ClientId     string `yaml:"client_id" toml:"client_id"`
+	ClientSecret string `yaml:"client_secret" toml:"client_secret"`
+	RedirectUri  string `yaml:"redirect_uri" toml:"redirect_uri"`
+	// APIURL and OAuthURL point the app at another server, e.g. a local
+	// fake strava server
+	APIURL   string `yaml:"api_url" toml:"api_url"`
+	OAuthURL string `yaml:"oauth_url" toml:"oauth_url"`
+}
+
+// Map are the settings of the race maps
+type Map struct {
+	// Backend is svg, or mapbox for static mapbox images
+	Backend string `yaml:"backend" toml:"backend"`
+	// PrivacyZones hide the start and end of routes inside a circle, as
+	// lat,lng,radius in meters, e.g. 40.7128,-74.0060,500
+	PrivacyZones []string `yaml:"privacy_zones" toml:"privacy_zones"`
+	// HideEnds hides the first and last meters of routes
+	HideEnds float64 `yaml:"hide_ends" toml:"hide_ends"`
+}
+
+// Mapbox are the settings of the mapbox map backend
+type Mapbox struct {
+	AccessToken string `yaml:"access_token" toml:"access_token"`
+}
+
+// Default returns the settings used when nothing else is set
+func Default() Config {
+	return Config{
+		DB:            "strava.db",
+		Dist:          "dist",
+		Templates:     "templates",
+		Static:        "static",
+		Club:          "Running Club",
+		Units:         utils.Imperial.Name,
+		RacePaceTime:  string(utils.ElapsedTime),
+		SplitPaceTime: string(utils.ElapsedTime),
+		Server:        Server{Port: 8080},
+		Races:         Races{SportTypes: []string{"Run"}, WorkoutTypes: []uint8{1}},
+		Strava:        Strava{RedirectUri: "http://localhost:8080/callback"},
+		Map:           Map{Backend: "svg"},
+	}
+}
+
+// Load returns the default settings overridden by the config file and
+// then by the environment. An empty path loads the first of the
+// DefaultFiles that exists, if any.
+func Load(path string) (Config, error) {
+	c := Default()
+
+	if path == "" {
+		for _, f := range DefaultFiles {
+			if _, err := os.Stat(f); err == nil {
+				path = f
+				break
+			}
+		}
+	}
+	if path != "" {
+		if err := c.loadFile(path); err != nil {
+			return c, err
+		}
+	}
+
+	if err := c.loadEnv(os.LookupEnv); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// loadFile overrides the settings set in a yaml or toml file
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("config file %s not found", path)
+		}
+		return err
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(c)
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	case ".toml":
+		var md toml.MetaData
+		md, err = toml.Decode(string(data), c)
+		if err == nil && len(md.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown setting %s", md.Undecoded()[0])
+		}
+	default:
+		return fmt.Errorf("config file %s must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv overrides the settings set in environment variables
+func (c *Config) loadEnv(lookup func(string) (string, bool)) error {
+	strs := []struct {
+		name  string
+		value *string
+	}{
+		{"RACES_DB", &c.DB},
+		{"RACES_DIST", &c.Dist},
+		{"RACES_TEMPLATES", &c.Templates},
+		{"RACES_STATIC", &c.Static},
+		{"RACES_CLUB", &c.Club},
+		{"RACES_UNITS", &c.Units},
+		{"RACES_RACE_PACE_TIME", &c.RacePaceTime},
+		{"RACES_SPLIT_PACE_TIME", &c.SplitPaceTime},
+		{"RACES_MAP", &c.Map.Backend},
+		{"STRAVA_CLIENT_ID", &c.Strava.ClientId},
+		{"STRAVA_CLIENT_SECRET", &c.Strava.ClientSecret},
+		{"STRAVA_REDIRECT_URI", &c.Strava.RedirectUri},
+		{"STRAVA_API_URL", &c.Strava.APIURL},
+		{"STRAVA_OAUTH_URL", &c.Strava.OAuthURL},
+		{"MAPBOX_ACCESS_TOKEN", &c.Mapbox.AccessToken},
+	}
+	for _, s := range strs {
+		if v, ok := lookup(s.name); ok && v != "" {
+			*s.value = v
+		}
+	}
+
+	// the mapbox token of the app environment, DEV unless APP_ENV is PRD
+	tokenEnv := "DEV_MAPBOX_ACCESS_TOKEN"
+	if v, _ := lookup("APP_ENV"); v == "PRD" {
+		tokenEnv = "PRD_MAPBOX_ACCESS_TOKEN"
+	}
+	if v, ok := lookup(tokenEnv); ok && v != "" {
+		c.Mapbox.AccessToken = v
+	}
+
+	lists := []struct {
+		name  string
+		value *[]string
+	}{
+		{"RACES_SPORT_TYPES", &c.Races.SportTypes},
+		{"RACES_NAME_KEYWORDS", &c.Races.NameKeywords},
+	}
+	for _, l := range lists {
+		if v, ok := lookup(l.name); ok && v != "" {
+			*l.value = splitList(v)
+		}
+	}
+
+	// privacy zones have commas, they are separated by semicolons
+	if v, ok := lookup("RACES_PRIVACY_ZONES"); ok && v != "" {
+		c.Map.PrivacyZones = nil
+		for _, z := range strings.Split(v, ";") {
+			if z = strings.TrimSpace(z); z != "" {
+				c.Map.PrivacyZones = append(c.Map.PrivacyZones, z)
+			}
+		}
+	}
+	if v, ok := lookup("RACES_HIDE_ENDS"); ok && v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid RACES_HIDE_ENDS %q, expected meters", v)
+		}
+		c.Map.HideEnds = f
+	}
+
+	if v, ok := lookup("RACES_WORKOUT_TYPES"); ok && v != "" {
+		c.Races.WorkoutTypes = nil
+		for _, s := range splitList(v) {
+			n, err := strconv.ParseUint(s, 10, 8)
+			if err != nil {
+				return fmt.Errorf("invalid RACES_WORKOUT_TYPES %q, expected numbers", v)
+			}
+			c.Races.WorkoutTypes = append(c.Races.WorkoutTypes, uint8(n))
+		}
+	}
+	ids := []struct {
+		name  string
+		value *[]uint64
+	}{
+		{"RACES_INCLUDE_IDS", &c.Races.IncludeIds},
+		{"RACES_EXCLUDE_IDS", &c.Races.ExcludeIds},
+	}
+	for _, l := range ids {
+		v, ok := lookup(l.name)
+		if !ok || v == "" {
+			continue
+		}
+		*l.value = nil
+		for _, s := range splitList(v) {
+			n, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q, expected strava activity ids", l.name, v)
+			}
+			*l.value = append(*l.value, n)
+		}
+	}
+
+	ints := []struct {
+		name  string
+		value *int
+	}{
+		{"RACES_PORT", &c.Server.Port},
+		{"MAX_HEARTRATE", &c.MaxHeartrate},
+	}
+	for _, i := range ints {
+		v, ok := lookup(i.name)
+		if !ok || v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q, expected a number", i.name, v)
+		}
+		*i.value = n
+	}
+	return nil
+}
+
+// splitList returns the trimmed values of a comma separated list
+func splitList(v string) []string {
+	var res []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// Validate returns an error for settings that can't work
+func (c Config) Validate() error {
+	paths := []struct {
+		name  string
+		value string
+	}{
+		{"db", c.DB},
+		{"dist", c.Dist},
+		{"templates", c.Templates},
+		{"static", c.Static},
+	}
+	for _, p := range paths {
+		if strings.TrimSpace(p.value) == "" {
+			return fmt.Errorf("%s must not be empty", p.name)
+		}
+	}
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		return fmt.Errorf("invalid server port %d", c.Server.Port)
+	}
+	if _, err := utils.ParseUnits(c.Units); err != nil {
+		return err
+	}
+	if _, err := utils.ParseTimeBasis(c.RacePaceTime); err != nil {
+		return fmt.Errorf("race_pace_time: %w", err)
+	}
+	if _, err := utils.ParseTimeBasis(c.SplitPaceTime); err != nil {
+		return fmt.Errorf("split_pace_time: %w", err)
+	}
+	if c.Map.HideEnds < 0 {
+		return fmt.Errorf("invalid map hide_ends %g, expected meters", c.Map.HideEnds)
+	}
+	if c.MaxHeartrate < 0 {
+		return fmt.Errorf("invalid max heart rate %d", c.MaxHeartrate)
+	}
+	for _, ids := range [][]uint64{c.Races.IncludeIds, c.Races.ExcludeIds} {
+		for _, id := range ids {
+			if id == 0 {
+				return fmt.Errorf("invalid strava activity id %d", id)
+			}
+		}
+	}
+	if _, err := c.RedirectPort(); err != nil {
+		return err
+	}
+	for _, u := range []string{c.Strava.APIURL, c.Strava.OAuthURL} {
+		if u == "" {
+			continue
+		}
+		if p, err := url.Parse(u); err != nil || p.Scheme == "" || p.Host == "" {
+			return fmt.Errorf("invalid strava url %q", u)
+		}
+	}
+	return nil
+}
+
+// RedirectPort returns the port of the strava redirect uri, where the
+// newtoken command waits for the authorization callback
+func (c Config) RedirectPort() (string, error) {
+	u, err := url.Parse(c.Strava.RedirectUri)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid strava redirect uri %q", c.Strava.RedirectUri)
+	}
+	if p := u.Port(); p != "" {
+		return p, nil
+	}
+	if u.Scheme == "https" {
+		return "443", nil
+	}
+	return "80", nil
+}
+
+// TemplateFiles returns the paths of template files in the templates directory
+func (c Config) TemplateFiles(names ...string) []string {
+	res := make([]string, len(names))
+	for i, n := range names {
+		res[i] = filepath.Join(c.Templates, n)
+	}
+	return res
+}
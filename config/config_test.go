@@ -0,0 +1,170 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFile(t *testing.T) {
+	testCases := []struct {
+		name string
+		data string
+	}{
+		{"races.yaml", "db: races.db\ndist: public\nserver:\n  port: 9090\nraces:\n  sport_types: [Run, Ride]\n  exclude_ids: [42]\nstrava:\n  client_id: \"123\"\n"},
+		{"races.toml", "db = \"races.db\"\ndist = \"public\"\n[server]\nport = 9090\n[races]\nsport_types = [\"Run\", \"Ride\"]\nexclude_ids = [42]\n[strava]\nclient_id = \"123\"\n"},
+	}
+	for _, tc := range testCases {
+		c := Default()
+		if err := c.loadFile(writeFile(t, tc.name, tc.data)); err != nil {
+			t.Fatalf("%s: unexpected error. %s", tc.name, err)
+		}
+		if c.DB != "races.db" || c.Dist != "public" || c.Server.Port != 9090 || c.Strava.ClientId != "123" {
+			t.Errorf("%s: settings were not loaded. Found(%+v)", tc.name, c)
+		}
+		if len(c.Races.SportTypes) != 2 || c.Races.SportTypes[1] != "Ride" || len(c.Races.ExcludeIds) != 1 || c.Races.ExcludeIds[0] != 42 {
+			t.Errorf("%s: race settings were not loaded. Found(%+v)", tc.name, c.Races)
+		}
+		if c.Templates != "templates" || c.Strava.RedirectUri != Default().Strava.RedirectUri ||
+			len(c.Races.WorkoutTypes) != 1 || c.Races.WorkoutTypes[0] != 1 {
+			t.Errorf("%s: expected defaults for settings not in the file. Found(%+v)", tc.name, c)
+		}
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{"races.yaml", "bd: races.db\n", "invalid config file"},
+		{"races.toml", "bd = \"races.db\"\n", "unknown setting bd"},
+		{"races.json", "{}", "must be .yaml, .yml or .toml"},
+	}
+	for _, tc := range testCases {
+		c := Default()
+		err := c.loadFile(writeFile(t, tc.name, tc.data))
+		if err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Errorf("%s: expected an error containing %q. Found(%v)", tc.name, tc.expected, err)
+		}
+	}
+
+	c := Default()
+	if err := c.loadFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Errorf("Expected an error for a missing config file")
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	env := map[string]string{
+		"RACES_DB":                "env.db",
+		"RACES_PORT":              "3000",
+		"RACES_CLUB":              "Queens Runners",
+		"RACES_SPORT_TYPES":       "Run, TrailRun",
+		"RACES_WORKOUT_TYPES":     "1,11",
+		"RACES_UNITS":             "km",
+		"RACES_MAP":               "mapbox",
+		"RACES_PRIVACY_ZONES":     "40.7128,-74.0060,500; 40.6,-73.9,300",
+		"RACES_HIDE_ENDS":         "200",
+		"STRAVA_CLIENT_SECRET":    "secret",
+		"APP_ENV":                 "PRD",
+		"DEV_MAPBOX_ACCESS_TOKEN": "dev",
+		"PRD_MAPBOX_ACCESS_TOKEN": "prd",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	c := Default()
+	c.DB = "file.db"
+	if err := c.loadEnv(lookup); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if c.DB != "env.db" || c.Server.Port != 3000 || c.Strava.ClientSecret != "secret" || c.Club != "Queens Runners" {
+		t.Errorf("Environment did not override the settings. Found(%+v)", c)
+	}
+	if len(c.Races.SportTypes) != 2 || c.Races.SportTypes[1] != "TrailRun" || len(c.Races.WorkoutTypes) != 2 {
+		t.Errorf("Environment did not override the race settings. Found(%+v)", c.Races)
+	}
+	if c.Units != "km" || c.Map.Backend != "mapbox" || len(c.Map.PrivacyZones) != 2 ||
+		c.Map.PrivacyZones[1] != "40.6,-73.9,300" || c.Map.HideEnds != 200 {
+		t.Errorf("Environment did not override the page settings. Found(%+v)", c)
+	}
+	if c.Mapbox.AccessToken != "prd" {
+		t.Errorf("Incorrect mapbox token. Found(%s), Expected(%s)", c.Mapbox.AccessToken, "prd")
+	}
+
+	env["RACES_WORKOUT_TYPES"] = "300"
+	if err := c.loadEnv(lookup); err == nil {
+		t.Errorf("Expected an error for an invalid workout type")
+	}
+	env["RACES_WORKOUT_TYPES"] = "1"
+	env["RACES_HIDE_ENDS"] = "far"
+	if err := c.loadEnv(lookup); err == nil {
+		t.Errorf("Expected an error for invalid hidden ends")
+	}
+	env["RACES_HIDE_ENDS"] = "0"
+	env["RACES_PORT"] = "http"
+	if err := c.loadEnv(lookup); err == nil {
+		t.Errorf("Expected an error for an invalid port")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Errorf("Unexpected error for the default settings. %s", err)
+	}
+
+	testCases := []struct {
+		name   string
+		modify func(c *Config)
+	}{
+		{"empty db", func(c *Config) { c.DB = "" }},
+		{"empty templates", func(c *Config) { c.Templates = " " }},
+		{"port out of range", func(c *Config) { c.Server.Port = 70000 }},
+		{"negative max heart rate", func(c *Config) { c.MaxHeartrate = -1 }},
+		{"redirect uri without host", func(c *Config) { c.Strava.RedirectUri = "/callback" }},
+		{"unknown units", func(c *Config) { c.Units = "yd" }},
+		{"unknown pace time", func(c *Config) { c.SplitPaceTime = "average" }},
+		{"negative hidden ends", func(c *Config) { c.Map.HideEnds = -1 }},
+		{"zero activity id", func(c *Config) { c.Races.ExcludeIds = []uint64{0} }},
+		{"api url without scheme", func(c *Config) { c.Strava.APIURL = "localhost:9000" }},
+	}
+	for _, tc := range testCases {
+		c := Default()
+		tc.modify(&c)
+		if err := c.Validate(); err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+}
+
+func TestRedirectPort(t *testing.T) {
+	testCases := []struct {
+		uri      string
+		expected string
+	}{
+		{"http://localhost:8080/callback", "8080"},
+		{"http://localhost/callback", "80"},
+		{"https://races.example.com/callback", "443"},
+	}
+	for _, tc := range testCases {
+		c := Default()
+		c.Strava.RedirectUri = tc.uri
+		if p, err := c.RedirectPort(); err != nil || p != tc.expected {
+			t.Errorf("Incorrect port for %s. Found(%s, %v), Expected(%s)", tc.uri, p, err, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// Name returns the full name of the athlete
+func (s StravaAthlete) Name() string {
+	return strings.TrimSpace(s.FirstName + " " + s.LastName)
+}
+
+// Slug returns the full name as a url path segment, e.g. david-d, or the
+// strava id when the athlete has no name
+func (s StravaAthlete) Slug() string {
+	slug := strings.Trim(re.ReplaceAllString(strings.ToLower(s.Name()), "-"), "-")
+	if slug == "" {
+		return strconv.FormatUint(s.StravaId, 10)
+	}
+	return slug
+}
+
+// SelectStravaAuthById selects and returns the strava_auth record of an athlete
+func (s *Store) SelectStravaAuthById(athleteId uint64) (*StravaAuth, error) {
+	return s.SelectStravaAuthByIdContext(context.Background(), athleteId)
+}
+
+func (s *Store) SelectStravaAuthByIdContext(ctx context.Context, athleteId uint64) (*StravaAuth, error) {
+	q := `SELECT access_token, access_token_expires_at, refresh_token, athlete_id
+            FROM strava_auth
+            WHERE athlete_id=?`
+	var res StravaAuth
+	if err := s.db.GetContext(ctx, &res, q, athleteId); err != nil {
+		return &res, err
+	}
+	return &res, nil
+}
+
+// AllStravaAuths returns the strava_auth records of every authorized athlete
+func (s *Store) AllStravaAuths() ([]StravaAuth, error) {
+	return s.AllStravaAuthsContext(context.Background())
+}
+
+func (s *Store) AllStravaAuthsContext(ctx context.Context) ([]StravaAuth, error) {
+	q := `SELECT access_token, access_token_expires_at, refresh_token, athlete_id
+            FROM strava_auth
+            ORDER BY athlete_id`
+	var res []StravaAuth
+	if err := s.db.SelectContext(ctx, &res, q); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// AllStravaAthletes returns every athlete ordered by name. Athletes with
+// races or tokens but without an athlete record are returned without a name.
+func (s *Store) AllStravaAthletes() ([]StravaAthlete, error) {
+	return s.AllStravaAthletesContext(context.Background())
+}
+
+func (s *Store) AllStravaAthletesContext(ctx context.Context) ([]StravaAthlete, error) {
+	q := `SELECT * FROM (
+                SELECT strava_id, first_name, last_name, COALESCE(profile, '') AS profile,
+                    COALESCE(profile_medium, '') AS profile_medium,
+                    COALESCE(latest_activity_datetime, '') AS latest_activity_datetime
+                FROM athlete
+                UNION
+                SELECT DISTINCT strava_athlete_id, '', '', '', '', ''
+                FROM race_activity
+                WHERE strava_athlete_id NOT IN (SELECT strava_id FROM athlete)
+                UNION
+                SELECT athlete_id, '', '', '', '', ''
+                FROM strava_auth
+                WHERE athlete_id NOT IN (SELECT strava_id FROM athlete)
+            )
+            ORDER BY first_name = '', first_name, last_name, strava_id`
+	var res []StravaAthlete
+	if err := s.db.SelectContext(ctx, &res, q); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// AthleteRaceActivities returns the race activities of an athlete that are not hidden
+func (s *Store) AthleteRaceActivities(athleteId uint64) ([]RaceActivity, error) {
+	return s.AthleteRaceActivitiesContext(context.Background(), athleteId)
+}
+
+func (s *Store) AthleteRaceActivitiesContext(ctx context.Context, athleteId uint64) ([]RaceActivity, error) {
+	var res []RaceActivity
+	q := `SELECT * from race_activity
+            WHERE strava_athlete_id=? AND hidden_at=''
+            ORDER BY start_date_local DESC`
+	if err := s.db.SelectContext(ctx, &res, q, athleteId); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// AthleteRaceActivitiesWithHidden returns the race activities of an athlete including hidden ones
+func (s *Store) AthleteRaceActivitiesWithHidden(athleteId uint64) ([]RaceActivity, error) {
+	return s.AthleteRaceActivitiesWithHiddenContext(context.Background(), athleteId)
+}
+
+func (s *Store) AthleteRaceActivitiesWithHiddenContext(ctx context.Context, athleteId uint64) ([]RaceActivity, error) {
+	var res []RaceActivity
+	q := `SELECT * from race_activity WHERE strava_athlete_id=? ORDER BY start_date_local DESC`
+	if err := s.db.SelectContext(ctx, &res, q, athleteId); err != nil {
+		return res, err
+	}
+	return res, nil
+}
@@ -0,0 +1,113 @@
+package db
+
+import "testing"
+
+func TestStravaAthleteSlug(t *testing.T) {
+	tests := []struct {
+		athlete  StravaAthlete
+		expected string
+	}{
+		{StravaAthlete{StravaId: 7, FirstName: "David", LastName: "D."}, "david-d"},
+		{StravaAthlete{StravaId: 7, FirstName: "Maria"}, "maria"},
+		{StravaAthlete{StravaId: 7}, "7"},
+	}
+	for _, test := range tests {
+		if slug := test.athlete.Slug(); slug != test.expected {
+			t.Errorf("Incorrect slug. Found(%s), Expected(%s)", slug, test.expected)
+		}
+	}
+}
+
+func TestAllStravaAthletes(t *testing.T) {
+	s := newTestStore(t)
+	for _, a := range []StravaAthlete{
+		{StravaId: 2, FirstName: "Maria", LastName: "L"},
+		{StravaId: 1, FirstName: "David", LastName: "D"},
+	} {
+		if err := s.InsertStravaAthelete(a); err != nil {
+			t.Fatalf("Unexpected error. %s", err)
+		}
+	}
+	r := newRace(10, "Club 5K", "2023-01-01T09:00:00Z")
+	r.AthleteId = 3
+	if err := s.InsertRaceActivity(r); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+
+	if err := s.InsertStravaAuth(StravaAuth{AccessToken: "a", RefreshToken: "r", AthleteId: 4}); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+
+	athletes, err := s.AllStravaAthletes()
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	var ids []uint64
+	for _, a := range athletes {
+		ids = append(ids, a.StravaId)
+	}
+	expected := []uint64{1, 2, 3, 4}
+	if len(ids) != len(expected) || ids[0] != 1 || ids[1] != 2 || ids[2] != 3 || ids[3] != 4 {
+		t.Errorf("Incorrect athletes. Found(%v), Expected(%v)", ids, expected)
+	}
+}
+
+func TestAthleteRaceActivities(t *testing.T) {
+	s := newTestStore(t)
+	for i, id := range []uint64{1, 2, 1} {
+		r := newRace(uint64(10+i), "Club 5K", "2023-01-01T09:00:00Z")
+		r.AthleteId = id
+		if err := s.InsertRaceActivity(r); err != nil {
+			t.Fatalf("Unexpected error. %s", err)
+		}
+	}
+	if err := s.HideRaceActivity(12, "deleted on strava"); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+
+	races, err := s.AthleteRaceActivities(1)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if len(races) != 1 || races[0].StravaId != 10 {
+		t.Errorf("Expected only the visible race of the athlete. Found(%v)", races)
+	}
+	races, err = s.AthleteRaceActivitiesWithHidden(1)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if len(races) != 2 {
+		t.Errorf("Incorrect number of races. Found(%d), Expected(%d)", len(races), 2)
+	}
+}
+
+func TestSelectStravaAuthById(t *testing.T) {
+	s := newTestStore(t)
+	for _, a := range []StravaAuth{
+		{AccessToken: "a1", RefreshToken: "r1", ExpiresAt: 1, AthleteId: 1},
+		{AccessToken: "a2", RefreshToken: "r2", ExpiresAt: 2, AthleteId: 2},
+	} {
+		if err := s.InsertStravaAuth(a); err != nil {
+			t.Fatalf("Unexpected error. %s", err)
+		}
+	}
+
+	sa, err := s.SelectStravaAuthById(2)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if sa.AccessToken != "a2" {
+		t.Errorf("Incorrect access token. Found(%s), Expected(%s)", sa.AccessToken, "a2")
+	}
+	if _, err := s.SelectStravaAuthById(3); err == nil || !IsEmptyResultSet(err.Error()) {
+		t.Errorf("Expected an empty result set. Found(%v)", err)
+	}
+
+	auths, err := s.AllStravaAuths()
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if len(auths) != 2 {
+		t.Errorf("Incorrect number of auths. Found(%d), Expected(%d)", len(auths), 2)
+	}
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"slices"
+)
+
+// RaceCategory is a standard race distance
+type RaceCategory struct {
+	Key    string
+	Name   string
+	Meters float64
+}
+
+// RaceCategories are the standard race distances from shortest to longest.
+// Races longer than a marathon are ultras.
+var RaceCategories = []RaceCategory{
+	{"1-mile", "1 Mile", 1609.344},
+	{"5k", "5K", 5000},
+	{"8k", "8K", 8000},
+	{"10k", "10K", 10000},
+	{"15k", "15K", 15000},
+	{"10-mile", "10 Mile", 16093.44},
+	{"half-marathon", "Half Marathon", 21097.5},
+	{"marathon", "Marathon", 42195},
+	{"ultra", "Ultra", 0},
+}
+
+// UltraCategory is the key of races longer than a marathon
+const UltraCategory = "ultra"
+
+// gps distances are usually a little longer than the certified course, and
+// sometimes shorter in tunnels or under tall buildings. The tolerances keep
+// close distances apart, e.g. 15K and 10 mile.
+const (
+	categoryLonger  = 0.04
+	categoryShorter = 0.03
+)
+
+// categorySports are the strava sport types classified by distance, the
+// standard distances are running race distances
+var categorySports = []string{"Run", "TrailRun", "VirtualRun"}
+
+// RaceCategoryByKey returns the category with a key, or false when there is none
+func RaceCategoryByKey(key string) (RaceCategory, bool) {
+	for _, c := range RaceCategories {
+		if c.Key == key {
+			return c, true
+		}
+	}
+	return RaceCategory{}, false
+}
+
+// ClassifyDistance returns the category of a race distance in meters,
+// or false when the distance is not close to a standard distance.
+func ClassifyDistance(meters float64) (RaceCategory, bool) {
+	var marathon RaceCategory
+	for _, c := range RaceCategories {
+		if c.Meters == 0 {
+			continue
+		}
+		if meters >= c.Meters*(1-categoryShorter) && meters <= c.Meters*(1+categoryLonger) {
+			return c, true
+		}
+		marathon = c
+	}
+	if meters > marathon.Meters*(1+categoryLonger) {
+		return RaceCategoryByKey(UltraCategory)
+	}
+	return RaceCategory{}, false
+}
+
+// Category returns the category set manually for the race activity, or the
+// category of its distance when it is a run. It returns false when the race
+// has no category.
+func (r RaceActivity) Category() (RaceCategory, bool) {
+	if r.CategoryOverride != "" {
+		return RaceCategoryByKey(r.CategoryOverride)
+	}
+	if !slices.Contains(categorySports, r.SportType) {
+		return RaceCategory{}, false
+	}
+	return ClassifyDistance(r.Distance)
+}
+
+// CategoryName returns the name of the race category, or an empty string
+func (r RaceActivity) CategoryName() string {
+	c, _ := r.Category()
+	return c.Name
+}
+
+// SetRaceCategory overrides the category of a race activity. An empty
+// category classifies the race by distance again.
+func (s *Store) SetRaceCategory(stravaId uint64, category string) error {
+	return s.SetRaceCategoryContext(context.Background(), stravaId, category)
+}
+
+func (s *Store) SetRaceCategoryContext(ctx context.Context, stravaId uint64, category string) error {
+	q := `UPDATE race_activity SET category_override=? WHERE strava_id=?`
+	_, err := s.db.ExecContext(ctx, q, category, stravaId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
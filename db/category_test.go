@@ -0,0 +1,28 @@
+package db
+
+import "testing"
+
+func TestRaceCategory(t *testing.T) {
+	testCases := []struct {
+		sportType string
+		distance  float64
+		override  string
+		expected  string
+	}{
+		{"Run", 42400, "", "marathon"},
+		{"TrailRun", 10150, "", "10k"},
+		{"Run", 12000, "", ""},
+		{"Run", 60000, "", "ultra"},
+		// the standard distances are running distances
+		{"Ride", 42195, "", ""},
+		{"Walk", 5000, "", ""},
+		{"Ride", 42195, "marathon", "marathon"},
+	}
+	for _, tc := range testCases {
+		r := RaceActivity{SportType: tc.sportType, Distance: tc.distance, CategoryOverride: tc.override}
+		c, ok := r.Category()
+		if c.Key != tc.expected || ok != (tc.expected != "") {
+			t.Errorf("Incorrect category of a %.0f m %s. Found(%s, %v), Expected(%s)", tc.distance, tc.sportType, c.Key, ok, tc.expected)
+		}
+	}
+}
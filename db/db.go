@@ -1,6 +1,8 @@
 package db
 
 import (
+	"context"
+	"fmt"
 	"regexp"
 	"strings"
 	"time"
@@ -9,7 +11,32 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
-var db = sqlx.MustConnect("sqlite3", "strava.db")
+// Store reads and writes the races data of a sqlite database.
+// Every query method Foo has a FooContext variant that uses a context for
+// the database calls, Foo runs it with context.Background().
+type Store struct {
+	db  *sqlx.DB
+	dsn string
+}
+
+// Open opens the sqlite database of a data source name, e.g. strava.db.
+// The :memory: database is limited to a single connection because every
+// connection would open a different in-memory database.
+func Open(dsn string) (*Store, error) {
+	conn, err := sqlx.Connect("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open database %s: %w", dsn, err)
+	}
+	if dsn == ":memory:" {
+		conn.SetMaxOpenConns(1)
+	}
+	return &Store{db: conn, dsn: dsn}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
 
 func IsEmptyResultSet(e string) bool {
 	return strings.Contains(e, "no rows in result set")
@@ -32,35 +59,34 @@ func (s *StravaAuth) IsExpired() bool {
 	return now.Unix() > int64(s.ExpiresAt)
 }
 
-// SelectStravaAuth selects and returns a single strava_auth record
-func SelectStravaAuth() (*StravaAuth, error) {
-	q := `SELECT access_token, access_token_expires_at, refresh_token, athlete_id
-            FROM strava_auth
-            LIMIT 1`
-	var res StravaAuth
-	if err := db.Get(&res, q); err != nil {
-		return &res, err
-	}
-	return &res, nil
+// InsertStravaAuth inserts a new strava_auth record
+func (s *Store) InsertStravaAuth(a StravaAuth) error {
+	return s.InsertStravaAuthContext(context.Background(), a)
 }
 
-// InsertStravaAuth inserts a new strava_auth record
-func InsertStravaAuth(a StravaAuth) error {
+func (s *Store) InsertStravaAuthContext(ctx context.Context, a StravaAuth) error {
 	q := `INSERT INTO strava_auth(access_token, access_token_expires_at, refresh_token, athlete_id)
             VALUES (?, ?, ?, ?)`
-	res := db.MustExec(q, a.AccessToken, a.ExpiresAt, a.RefreshToken, a.AthleteId)
-	_, err := res.LastInsertId()
+	res, err := s.db.ExecContext(ctx, q, a.AccessToken, a.ExpiresAt, a.RefreshToken, a.AthleteId)
+	if err != nil {
+		return err
+	}
+	_, err = res.LastInsertId()
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func UpdateStravaAuth(sa StravaAuth) error {
+func (s *Store) UpdateStravaAuth(sa StravaAuth) error {
+	return s.UpdateStravaAuthContext(context.Background(), sa)
+}
+
+func (s *Store) UpdateStravaAuthContext(ctx context.Context, sa StravaAuth) error {
 	q := `UPDATE strava_auth
             SET access_token=?, access_token_expires_at=?, refresh_token=?
             WHERE athlete_id=?`
-	_, err := db.Exec(q, sa.AccessToken, sa.ExpiresAt, sa.RefreshToken, sa.AthleteId)
+	_, err := s.db.ExecContext(ctx, q, sa.AccessToken, sa.ExpiresAt, sa.RefreshToken, sa.AthleteId)
 	if err != nil {
 		return err
 	}
@@ -81,19 +107,27 @@ func (s *StravaAthlete) Exists() bool {
 	return s.StravaId > 0
 }
 
-func SelectLatestActivityDateTime(athleteId uint64) (string, error) {
+func (s *Store) SelectLatestActivityDateTime(athleteId uint64) (string, error) {
+	return s.SelectLatestActivityDateTimeContext(context.Background(), athleteId)
+}
+
+func (s *Store) SelectLatestActivityDateTimeContext(ctx context.Context, athleteId uint64) (string, error) {
 	q := `SELECT latest_activity_datetime FROM athlete WHERE strava_id=?`
 	var dt string
-	err := db.Get(&dt, q, athleteId)
+	err := s.db.GetContext(ctx, &dt, q, athleteId)
 	if err != nil {
 		return dt, err
 	}
 	return dt, nil
 }
 
-func UpdateLatestActivityDateTime(athleteId uint64, dt string) error {
+func (s *Store) UpdateLatestActivityDateTime(athleteId uint64, dt string) error {
+	return s.UpdateLatestActivityDateTimeContext(context.Background(), athleteId, dt)
+}
+
+func (s *Store) UpdateLatestActivityDateTimeContext(ctx context.Context, athleteId uint64, dt string) error {
 	q := `UPDATE athlete SET latest_activity_datetime=? WHERE strava_id=?`
-	res, err := db.Exec(q, dt, athleteId)
+	res, err := s.db.ExecContext(ctx, q, dt, athleteId)
 	if err != nil {
 		return err
 	}
@@ -105,12 +139,16 @@ func UpdateLatestActivityDateTime(athleteId uint64, dt string) error {
 }
 
 // SelectStravaAthleteById selects and returns a single strava athlete record
-func SelectStravaAthleteById(athleteId uint64) (*StravaAthlete, error) {
+func (s *Store) SelectStravaAthleteById(athleteId uint64) (*StravaAthlete, error) {
+	return s.SelectStravaAthleteByIdContext(context.Background(), athleteId)
+}
+
+func (s *Store) SelectStravaAthleteByIdContext(ctx context.Context, athleteId uint64) (*StravaAthlete, error) {
 	q := `SELECT strava_id, first_name, last_name, profile, profile_medium
             FROM athlete
             WHERE strava_id=?`
 	var res StravaAthlete
-	err := db.Get(&res, q, athleteId)
+	err := s.db.GetContext(ctx, &res, q, athleteId)
 	if err != nil {
 		return &res, err
 	}
@@ -118,10 +156,17 @@ func SelectStravaAthleteById(athleteId uint64) (*StravaAthlete, error) {
 }
 
 // InsertStravaAthelete inserts a new strava athlete record
-func InsertStravaAthelete(a StravaAthlete) error {
+func (s *Store) InsertStravaAthelete(a StravaAthlete) error {
+	return s.InsertStravaAtheleteContext(context.Background(), a)
+}
+
+func (s *Store) InsertStravaAtheleteContext(ctx context.Context, a StravaAthlete) error {
 	q := `INSERT INTO athlete(strava_id, first_name, last_name, profile, profile_medium) VALUES(?, ?, ?, ?, ?)`
-	res := db.MustExec(q, a.StravaId, a.FirstName, a.LastName, a.Profile, a.ProfileMedium)
-	_, err := res.LastInsertId()
+	res, err := s.db.ExecContext(ctx, q, a.StravaId, a.FirstName, a.LastName, a.Profile, a.ProfileMedium)
+	if err != nil {
+		return err
+	}
+	_, err = res.LastInsertId()
 	if err != nil {
 		return err
 	}
@@ -135,20 +180,32 @@ func (dt DateTime) parsed() (time.Time, error) {
 }
 
 type RaceActivity struct {
-	StravaId    uint64   `db:"strava_id"`
-	AthleteId   uint64   `db:"strava_athlete_id"`
-	Name        string   `db:"name"`
-	Distance    float64  `db:"distance"`
-	MovingTime  uint32   `db:"moving_time"`
-	ElapsedTime uint32   `db:"elapsed_time"`
-	StartDate   DateTime `db:"start_date_local"`
-	Polyline    string   `db:"polyline"`
+	StravaId         uint64   `db:"strava_id"`
+	AthleteId        uint64   `db:"strava_athlete_id"`
+	Name             string   `db:"name"`
+	SportType        string   `db:"sport_type"`
+	Distance         float64  `db:"distance"`
+	MovingTime       uint32   `db:"moving_time"`
+	ElapsedTime      uint32   `db:"elapsed_time"`
+	StartDate        DateTime `db:"start_date_local"`
+	Polyline         string   `db:"polyline"`
+	SyncedAt         string   `db:"synced_at"`
+	HiddenAt         string   `db:"hidden_at"`
+	HiddenReason     string   `db:"hidden_reason"`
+	RawJSON          string   `db:"raw_json"`
+	CategoryOverride string   `db:"category_override"`
 }
 
 func (r RaceActivity) Exists() bool {
 	return r.StravaId > 0
 }
 
+// IsHidden returns true when the race activity was hidden from the site,
+// e.g. because it was deleted on strava
+func (r RaceActivity) IsHidden() bool {
+	return r.HiddenAt != ""
+}
+
 // StartDateFormatted parses a datetime string and returns a
 // formatted date with the following layout: Mon, 02 Jan 2006 15:04:05 MST
 func (r RaceActivity) StartDateFormatted() (string, error) {
@@ -159,6 +216,15 @@ func (r RaceActivity) StartDateFormatted() (string, error) {
 	return t.Format(time.RFC1123), nil
 }
 
+// StartDateShort returns the start date with the following layout: Jan 2, 2006
+func (r RaceActivity) StartDateShort() (string, error) {
+	t, err := r.StartDate.parsed()
+	if err != nil {
+		return "", err
+	}
+	return t.Format("Jan 2, 2006"), nil
+}
+
 // RaceYear parses the StartDate string and returns the year of activity
 func (r RaceActivity) RaceYear() (int, error) {
 	t, err := r.StartDate.parsed()
@@ -174,53 +240,251 @@ func (r *RaceActivity) NameSlugified() string {
 	return strings.Trim(re.ReplaceAllString(strings.ToLower(r.Name), "-"), "-")
 }
 
+var sportWordRe = regexp.MustCompile("([a-z])([A-Z])")
+
+// SportName returns the strava sport type as words, e.g. TrailRun becomes Trail Run
+func (r RaceActivity) SportName() string {
+	return SportName(r.SportType)
+}
+
+// SportName returns a strava sport type as words, e.g. TrailRun becomes Trail Run
+func SportName(sportType string) string {
+	return sportWordRe.ReplaceAllString(sportType, "$1 $2")
+}
+
+// SportSlugified returns the sport type as a url path segment, e.g. trail-run
+func (r RaceActivity) SportSlugified() string {
+	return strings.Trim(re.ReplaceAllString(strings.ToLower(r.SportName()), "-"), "-")
+}
+
+func syncedAtNow() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
 // InsertRaceActivity inserts a new race_activity record
-func InsertRaceActivity(r RaceActivity) error {
+func (s *Store) InsertRaceActivity(r RaceActivity) error {
+	return s.InsertRaceActivityContext(context.Background(), r)
+}
+
+func (s *Store) InsertRaceActivityContext(ctx context.Context, r RaceActivity) error {
 	q := `INSERT INTO race_activity(
             strava_id,
             strava_athlete_id,
             name,
+            sport_type,
             distance,
             moving_time,
             elapsed_time,
             start_date_local,
-            polyline
-        ) VALUES(?, ?, ?, ?, ?, ?, ?, ?)`
-	res := db.MustExec(
-		q, r.StravaId, r.AthleteId, r.Name, r.Distance, r.MovingTime,
-		r.ElapsedTime, r.StartDate, r.Polyline,
+            polyline,
+            raw_json,
+            synced_at
+        ) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := s.db.ExecContext(
+		ctx, q, r.StravaId, r.AthleteId, r.Name, r.SportType, r.Distance, r.MovingTime,
+		r.ElapsedTime, r.StartDate, r.Polyline, r.RawJSON, syncedAtNow(),
+	)
+	if err != nil {
+		return err
+	}
+	_, err = res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateRaceActivity updates the strava data of an existing race_activity record
+func (s *Store) UpdateRaceActivity(r RaceActivity) error {
+	return s.UpdateRaceActivityContext(context.Background(), r)
+}
+
+func (s *Store) UpdateRaceActivityContext(ctx context.Context, r RaceActivity) error {
+	q := `UPDATE race_activity
+            SET name=?, sport_type=?, distance=?, moving_time=?, elapsed_time=?,
+                start_date_local=?, polyline=?, raw_json=?, synced_at=?
+            WHERE strava_id=?`
+	_, err := s.db.ExecContext(
+		ctx, q, r.Name, r.SportType, r.Distance, r.MovingTime, r.ElapsedTime,
+		r.StartDate, r.Polyline, r.RawJSON, syncedAtNow(), r.StravaId,
 	)
-	_, err := res.LastInsertId()
 	if err != nil {
 		return err
 	}
+	return nil
+}
+
+// TouchRaceActivity records that a race_activity record was synced without
+// changes to its summarized fields. The raw json is still saved because it
+// includes fields that change often, e.g. kudos.
+func (s *Store) TouchRaceActivity(r RaceActivity) error {
+	return s.TouchRaceActivityContext(context.Background(), r)
+}
 
+func (s *Store) TouchRaceActivityContext(ctx context.Context, r RaceActivity) error {
+	q := `UPDATE race_activity SET raw_json=?, synced_at=? WHERE strava_id=?`
+	_, err := s.db.ExecContext(ctx, q, r.RawJSON, syncedAtNow(), r.StravaId)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
-func SelectRaceActivityId(stravaId uint64) (uint64, error) {
+// FieldChange is a race activity field whose value changed
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+func (f FieldChange) String() string {
+	return fmt.Sprintf("%s: %s -> %s", f.Field, f.Old, f.New)
+}
+
+// RaceActivityChanges returns the strava data fields that differ between
+// the stored and the fresh race activity
+func RaceActivityChanges(stored, fresh RaceActivity) []FieldChange {
+	var changes []FieldChange
+	add := func(field, old, new string) {
+		if old != new {
+			changes = append(changes, FieldChange{Field: field, Old: old, New: new})
+		}
+	}
+	add("name", stored.Name, fresh.Name)
+	add("sport_type", stored.SportType, fresh.SportType)
+	add("distance", fmt.Sprintf("%.1f", stored.Distance), fmt.Sprintf("%.1f", fresh.Distance))
+	add("moving_time", fmt.Sprintf("%d", stored.MovingTime), fmt.Sprintf("%d", fresh.MovingTime))
+	add("elapsed_time", fmt.Sprintf("%d", stored.ElapsedTime), fmt.Sprintf("%d", fresh.ElapsedTime))
+	add("start_date_local", string(stored.StartDate), string(fresh.StartDate))
+	if stored.Polyline != fresh.Polyline {
+		// polylines are too long to show, only show their size
+		changes = append(changes, FieldChange{
+			Field: "polyline",
+			Old:   fmt.Sprintf("%d chars", len(stored.Polyline)),
+			New:   fmt.Sprintf("%d chars", len(fresh.Polyline)),
+		})
+	}
+	return changes
+}
+
+// UpsertResult describes what UpsertRaceActivity did
+type UpsertResult struct {
+	Inserted bool
+	Changes  []FieldChange
+}
+
+// UpsertRaceActivity inserts a new race_activity record or updates the
+// stored record when its strava data changed. The raw json of an unchanged
+// race with saved details is kept.
+func (s *Store) UpsertRaceActivity(r RaceActivity) (UpsertResult, error) {
+	return s.UpsertRaceActivityContext(context.Background(), r)
+}
+
+func (s *Store) UpsertRaceActivityContext(ctx context.Context, r RaceActivity) (UpsertResult, error) {
+	stored, err := s.SelectRaceActivityByIdContext(ctx, r.StravaId)
+	if err != nil {
+		if !IsEmptyResultSet(err.Error()) {
+			return UpsertResult{}, err
+		}
+		return UpsertResult{Inserted: true}, s.InsertRaceActivityContext(ctx, r)
+	}
+
+	// the stored json of a race with details is from the detailed activity,
+	// do not replace it with the summary, even when the race changed
+	hasDetails, err := s.HasRaceDetailsContext(ctx, r.StravaId)
+	if err != nil {
+		return UpsertResult{}, err
+	}
+	if hasDetails {
+		r.RawJSON = stored.RawJSON
+	}
+
+	changes := RaceActivityChanges(stored, r)
+	if len(changes) == 0 {
+		return UpsertResult{}, s.TouchRaceActivityContext(ctx, r)
+	}
+	return UpsertResult{Changes: changes}, s.UpdateRaceActivityContext(ctx, r)
+}
+
+func (s *Store) SelectRaceActivityId(stravaId uint64) (uint64, error) {
+	return s.SelectRaceActivityIdContext(context.Background(), stravaId)
+}
+
+func (s *Store) SelectRaceActivityIdContext(ctx context.Context, stravaId uint64) (uint64, error) {
 	var sid uint64
-	err := db.Get(&sid, "SELECT strava_id from race_activity where strava_id=?", stravaId)
+	err := s.db.GetContext(ctx, &sid, "SELECT strava_id from race_activity where strava_id=?", stravaId)
 	if err != nil {
 		return sid, err
 	}
 	return sid, nil
 }
 
-func SelectRaceActivityById(stravaId uint64) (RaceActivity, error) {
+func (s *Store) SelectRaceActivityById(stravaId uint64) (RaceActivity, error) {
+	return s.SelectRaceActivityByIdContext(context.Background(), stravaId)
+}
+
+func (s *Store) SelectRaceActivityByIdContext(ctx context.Context, stravaId uint64) (RaceActivity, error) {
 	var resp RaceActivity
-	err := db.Get(&resp, "SELECT * from race_activity where strava_id=?", stravaId)
+	err := s.db.GetContext(ctx, &resp, "SELECT * from race_activity where strava_id=?", stravaId)
 	if err != nil {
 		return resp, err
 	}
 	return resp, nil
 }
 
-func AllRaceActivities() ([]RaceActivity, error) {
+// AllRaceActivities returns all race activities that are not hidden
+func (s *Store) AllRaceActivities() ([]RaceActivity, error) {
+	return s.AllRaceActivitiesContext(context.Background())
+}
+
+func (s *Store) AllRaceActivitiesContext(ctx context.Context) ([]RaceActivity, error) {
 	var res []RaceActivity
-	err := db.Select(&res, "SELECT * from race_activity ORDER BY start_date_local DESC")
+	err := s.db.SelectContext(ctx, &res, "SELECT * from race_activity WHERE hidden_at='' ORDER BY start_date_local DESC")
 	if err != nil {
 		return res, err
 	}
 	return res, nil
 }
+
+// AllRaceActivitiesWithHidden returns all race activities including hidden ones
+func (s *Store) AllRaceActivitiesWithHidden() ([]RaceActivity, error) {
+	return s.AllRaceActivitiesWithHiddenContext(context.Background())
+}
+
+func (s *Store) AllRaceActivitiesWithHiddenContext(ctx context.Context) ([]RaceActivity, error) {
+	var res []RaceActivity
+	err := s.db.SelectContext(ctx, &res, "SELECT * from race_activity ORDER BY start_date_local DESC")
+	if err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// HideRaceActivity hides a race activity from the site without deleting it
+func (s *Store) HideRaceActivity(stravaId uint64, reason string) error {
+	return s.HideRaceActivityContext(context.Background(), stravaId, reason)
+}
+
+func (s *Store) HideRaceActivityContext(ctx context.Context, stravaId uint64, reason string) error {
+	q := `UPDATE race_activity SET hidden_at=?, hidden_reason=? WHERE strava_id=?`
+	_, err := s.db.ExecContext(ctx, q, time.Now().UTC().Format(time.RFC3339), reason, stravaId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UnhideRaceActivity shows a hidden race activity on the site again
+func (s *Store) UnhideRaceActivity(stravaId uint64) error {
+	return s.UnhideRaceActivityContext(context.Background(), stravaId)
+}
+
+func (s *Store) UnhideRaceActivityContext(ctx context.Context, stravaId uint64) error {
+	q := `UPDATE race_activity SET hidden_at='', hidden_reason='' WHERE strava_id=?`
+	_, err := s.db.ExecContext(ctx, q, stravaId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
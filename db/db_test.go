@@ -0,0 +1,239 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// newTestStore returns a migrated in-memory store
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	if _, err := s.MigrateUp(); err != nil {
+		t.Fatalf("Unable to migrate. %s", err)
+	}
+	return s
+}
+
+func newRace(id uint64, name, start string) RaceActivity {
+	return RaceActivity{
+		StravaId:    id,
+		AthleteId:   7,
+		Name:        name,
+		SportType:   "Run",
+		Distance:    5000,
+		MovingTime:  1200,
+		ElapsedTime: 1230,
+		StartDate:   DateTime(start),
+		Polyline:    "_p~iF~ps|U_ulLnnqC_mqNvxq`@",
+	}
+}
+
+func TestUpsertRaceActivity(t *testing.T) {
+	s := newTestStore(t)
+	r := newRace(1, "Turkey Trot", "2023-11-23T08:00:00Z")
+
+	res, err := s.UpsertRaceActivity(r)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if !res.Inserted {
+		t.Errorf("Expected a new race to be inserted. Found(%+v)", res)
+	}
+
+	res, err = s.UpsertRaceActivity(r)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if res.Inserted || len(res.Changes) != 0 {
+		t.Errorf("Expected an unchanged race to be touched. Found(%+v)", res)
+	}
+
+	r.Name = "Turkey Trot 5K"
+	res, err = s.UpsertRaceActivity(r)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if len(res.Changes) != 1 || res.Changes[0].Field != "name" {
+		t.Errorf("Incorrect changes. Found(%v), Expected(%v)", res.Changes, "name")
+	}
+
+	stored, err := s.SelectRaceActivityById(1)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if stored.Name != r.Name {
+		t.Errorf("Incorrect race name. Found(%s), Expected(%s)", stored.Name, r.Name)
+	}
+}
+
+func TestUpsertKeepsDetailedJSON(t *testing.T) {
+	s := newTestStore(t)
+	r := newRace(1, "Turkey Trot", "2023-11-23T08:00:00Z")
+	r.RawJSON = `{"id":1}`
+	if _, err := s.UpsertRaceActivity(r); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+
+	detailed := `{"id":1,"device_name":"Garmin"}`
+	if err := s.TouchRaceActivity(RaceActivity{StravaId: 1, RawJSON: detailed}); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	details := RaceDetails{Splits: []RaceSplit{{Kind: SplitStandard, Split: 1, Distance: 1609}}}
+	if err := s.SaveRaceDetails(1, details); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+
+	if _, err := s.UpsertRaceActivity(r); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	stored, err := s.SelectRaceActivityById(1)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if stored.RawJSON != detailed {
+		t.Errorf("Incorrect raw json. Found(%s), Expected(%s)", stored.RawJSON, detailed)
+	}
+
+	// a changed race keeps the detailed json until its details are fetched again
+	r.Name = "Turkey Trot 5K"
+	res, err := s.UpsertRaceActivity(r)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if len(res.Changes) == 0 {
+		t.Errorf("Expected the race to change")
+	}
+	stored, err = s.SelectRaceActivityById(1)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if stored.Name != r.Name || stored.RawJSON != detailed {
+		t.Errorf("Incorrect changed race. Found(%s %s), Expected(%s %s)", stored.Name, stored.RawJSON, r.Name, detailed)
+	}
+}
+
+func TestHideRaceActivity(t *testing.T) {
+	s := newTestStore(t)
+	for _, r := range []RaceActivity{
+		newRace(1, "First 5K", "2023-01-01T09:00:00Z"),
+		newRace(2, "Second 5K", "2023-02-01T09:00:00Z"),
+	} {
+		if err := s.InsertRaceActivity(r); err != nil {
+			t.Fatalf("Unexpected error. %s", err)
+		}
+	}
+	if err := s.HideRaceActivity(2, "deleted on strava"); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+
+	races, err := s.AllRaceActivities()
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if len(races) != 1 || races[0].StravaId != 1 {
+		t.Errorf("Expected only the visible race. Found(%v)", races)
+	}
+	all, err := s.AllRaceActivitiesWithHidden()
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if len(all) != 2 || all[0].StravaId != 2 || !all[0].IsHidden() {
+		t.Errorf("Expected the hidden race newest first. Found(%v)", all)
+	}
+
+	if err := s.UnhideRaceActivity(2); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	races, err = s.AllRaceActivities()
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if len(races) != 2 {
+		t.Errorf("Incorrect number of races. Found(%d), Expected(%d)", len(races), 2)
+	}
+}
+
+func TestRaceStreams(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.InsertRaceActivity(newRace(1, "First 5K", "2023-01-01T09:00:00Z")); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+
+	exists, err := s.HasRaceStreams(1)
+	if err != nil || exists {
+		t.Errorf("Expected no streams. Found(%v, %v)", exists, err)
+	}
+
+	saved := RaceStreams{
+		LatLng:    [][2]float64{{40.6, -74.05}, {40.61, -74.04}},
+		Time:      []int{0, 10},
+		Distance:  []float64{0, 31.4},
+		Heartrate: []int{120, 131},
+	}
+	if err := s.SaveRaceStreams(1, saved); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	exists, err = s.HasRaceStreams(1)
+	if err != nil || !exists {
+		t.Errorf("Expected saved streams. Found(%v, %v)", exists, err)
+	}
+
+	found, err := s.SelectRaceStreams(1)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if len(found.LatLng) != 2 || found.LatLng[1] != saved.LatLng[1] || found.Distance[1] != 31.4 ||
+		found.Heartrate[1] != 131 || len(found.Altitude) != 0 {
+		t.Errorf("Incorrect streams. Found(%+v), Expected(%+v)", found, saved)
+	}
+}
+
+func TestRaceWithoutStreams(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.InsertRaceActivity(newRace(1, "Treadmill 5K", "2023-01-01T09:00:00Z")); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+
+	if err := s.SaveRaceStreams(1, RaceStreams{}); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	exists, err := s.HasRaceStreams(1)
+	if err != nil || !exists {
+		t.Errorf("Expected the race without streams to be saved. Found(%v, %v)", exists, err)
+	}
+	found, err := s.SelectRaceStreams(1)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if !found.IsEmpty() {
+		t.Errorf("Incorrect streams. Found(%+v), Expected(empty)", found)
+	}
+
+	// streams saved later replace the marker
+	if err := s.SaveRaceStreams(1, RaceStreams{Time: []int{0, 10}}); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	found, err = s.SelectRaceStreams(1)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if len(found.Time) != 2 {
+		t.Errorf("Incorrect streams. Found(%+v), Expected(2 time points)", found)
+	}
+}
+
+func TestCanceledContext(t *testing.T) {
+	s := newTestStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := s.AllRaceActivitiesContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a canceled error. Found(%v)", err)
+	}
+}
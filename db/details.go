@@ -0,0 +1,166 @@
+package db
+
+import "context"
+
+// Split kinds stored in race_split
+const (
+	SplitStandard = "standard"
+	SplitMetric   = "metric"
+)
+
+// RaceSplit represents db table `race_split`
+type RaceSplit struct {
+	StravaId            uint64  `db:"strava_id"`
+	Kind                string  `db:"kind"`
+	Split               uint16  `db:"split"`
+	Distance            float64 `db:"distance"`
+	ElapsedTime         uint32  `db:"elapsed_time"`
+	MovingTime          uint32  `db:"moving_time"`
+	ElevationDifference float64 `db:"elevation_difference"`
+	AverageSpeed        float64 `db:"average_speed"`
+	AverageHeartrate    float64 `db:"average_heartrate"`
+}
+
+// RaceLap represents db table `race_lap`
+type RaceLap struct {
+	StravaId           uint64  `db:"strava_id"`
+	LapIndex           uint16  `db:"lap_index"`
+	Name               string  `db:"name"`
+	Distance           float64 `db:"distance"`
+	ElapsedTime        uint32  `db:"elapsed_time"`
+	MovingTime         uint32  `db:"moving_time"`
+	TotalElevationGain float64 `db:"total_elevation_gain"`
+	AverageSpeed       float64 `db:"average_speed"`
+	AverageHeartrate   float64 `db:"average_heartrate"`
+}
+
+// RaceBestEffort represents db table `race_best_effort`
+type RaceBestEffort struct {
+	StravaId    uint64  `db:"strava_id"`
+	Name        string  `db:"name"`
+	Distance    float64 `db:"distance"`
+	ElapsedTime uint32  `db:"elapsed_time"`
+	MovingTime  uint32  `db:"moving_time"`
+	PrRank      uint8   `db:"pr_rank"`
+}
+
+// RaceDetails holds the detailed activity data of a race
+type RaceDetails struct {
+	Splits      []RaceSplit
+	Laps        []RaceLap
+	BestEfforts []RaceBestEffort
+}
+
+// SaveRaceDetails replaces the splits, laps and best efforts of a race activity
+func (s *Store) SaveRaceDetails(stravaId uint64, d RaceDetails) error {
+	return s.SaveRaceDetailsContext(context.Background(), stravaId, d)
+}
+
+func (s *Store) SaveRaceDetailsContext(ctx context.Context, stravaId uint64, d RaceDetails) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"race_split", "race_lap", "race_best_effort"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE strava_id=?", stravaId); err != nil {
+			return err
+		}
+	}
+
+	for _, sp := range d.Splits {
+		q := `INSERT INTO race_split(
+                strava_id, kind, split, distance, elapsed_time, moving_time,
+                elevation_difference, average_speed, average_heartrate
+            ) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		_, err := tx.ExecContext(ctx, q, stravaId, sp.Kind, sp.Split, sp.Distance, sp.ElapsedTime, sp.MovingTime,
+			sp.ElevationDifference, sp.AverageSpeed, sp.AverageHeartrate)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, l := range d.Laps {
+		q := `INSERT INTO race_lap(
+                strava_id, lap_index, name, distance, elapsed_time, moving_time,
+                total_elevation_gain, average_speed, average_heartrate
+            ) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		_, err := tx.ExecContext(ctx, q, stravaId, l.LapIndex, l.Name, l.Distance, l.ElapsedTime, l.MovingTime,
+			l.TotalElevationGain, l.AverageSpeed, l.AverageHeartrate)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, e := range d.BestEfforts {
+		q := `INSERT OR REPLACE INTO race_best_effort(
+                strava_id, name, distance, elapsed_time, moving_time, pr_rank
+            ) VALUES(?, ?, ?, ?, ?, ?)`
+		_, err := tx.ExecContext(ctx, q, stravaId, e.Name, e.Distance, e.ElapsedTime, e.MovingTime, e.PrRank)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// HasRaceDetails returns true when splits, laps or best efforts are saved for a race activity
+func (s *Store) HasRaceDetails(stravaId uint64) (bool, error) {
+	return s.HasRaceDetailsContext(context.Background(), stravaId)
+}
+
+func (s *Store) HasRaceDetailsContext(ctx context.Context, stravaId uint64) (bool, error) {
+	q := `SELECT EXISTS(SELECT 1 FROM race_split WHERE strava_id=?)
+            OR EXISTS(SELECT 1 FROM race_lap WHERE strava_id=?)
+            OR EXISTS(SELECT 1 FROM race_best_effort WHERE strava_id=?)`
+	var exists bool
+	err := s.db.GetContext(ctx, &exists, q, stravaId, stravaId, stravaId)
+	if err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// SelectRaceSplits returns the splits of a kind for a race activity
+func (s *Store) SelectRaceSplits(stravaId uint64, kind string) ([]RaceSplit, error) {
+	return s.SelectRaceSplitsContext(context.Background(), stravaId, kind)
+}
+
+func (s *Store) SelectRaceSplitsContext(ctx context.Context, stravaId uint64, kind string) ([]RaceSplit, error) {
+	var res []RaceSplit
+	err := s.db.SelectContext(ctx, &res, "SELECT * FROM race_split WHERE strava_id=? AND kind=? ORDER BY split", stravaId, kind)
+	if err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// SelectRaceLaps returns the laps of a race activity
+func (s *Store) SelectRaceLaps(stravaId uint64) ([]RaceLap, error) {
+	return s.SelectRaceLapsContext(context.Background(), stravaId)
+}
+
+func (s *Store) SelectRaceLapsContext(ctx context.Context, stravaId uint64) ([]RaceLap, error) {
+	var res []RaceLap
+	err := s.db.SelectContext(ctx, &res, "SELECT * FROM race_lap WHERE strava_id=? ORDER BY lap_index", stravaId)
+	if err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// SelectRaceBestEfforts returns the best efforts of a race activity from shortest to longest
+func (s *Store) SelectRaceBestEfforts(stravaId uint64) ([]RaceBestEffort, error) {
+	return s.SelectRaceBestEffortsContext(context.Background(), stravaId)
+}
+
+func (s *Store) SelectRaceBestEffortsContext(ctx context.Context, stravaId uint64) ([]RaceBestEffort, error) {
+	var res []RaceBestEffort
+	err := s.db.SelectContext(ctx, &res, "SELECT * FROM race_best_effort WHERE strava_id=? ORDER BY distance", stravaId)
+	if err != nil {
+		return res, err
+	}
+	return res, nil
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"encoding/json"
+)
+
+// ActivityExtra holds fields of the strava activity summary that are not
+// stored in their own race_activity columns. They are read from the raw json.
+type ActivityExtra struct {
+	TotalElevationGain float64   `json:"total_elevation_gain"`
+	ElevHigh           float64   `json:"elev_high"`
+	ElevLow            float64   `json:"elev_low"`
+	AverageSpeed       float64   `json:"average_speed"`
+	MaxSpeed           float64   `json:"max_speed"`
+	AverageHeartrate   float64   `json:"average_heartrate"`
+	MaxHeartrate       float64   `json:"max_heartrate"`
+	AverageCadence     float64   `json:"average_cadence"`
+	KudosCount         uint32    `json:"kudos_count"`
+	AchievementCount   uint32    `json:"achievement_count"`
+	StartLatlng        []float64 `json:"start_latlng"`
+	EndLatlng          []float64 `json:"end_latlng"`
+	Timezone           string    `json:"timezone"`
+	GearId             string    `json:"gear_id"`
+	DeviceName         string    `json:"device_name"`
+}
+
+// HasHeartrate returns true when the activity was recorded with a heart rate monitor
+func (e ActivityExtra) HasHeartrate() bool {
+	return e.AverageHeartrate > 0
+}
+
+// StartLocation returns the latitude and longitude where the activity started
+func (e ActivityExtra) StartLocation() (float64, float64, bool) {
+	if len(e.StartLatlng) != 2 {
+		return 0, 0, false
+	}
+	return e.StartLatlng[0], e.StartLatlng[1], true
+}
+
+// HasRaw returns true when the strava activity json is stored for the race activity.
+// Races saved before the json was stored get it with `fetch --full`.
+func (r RaceActivity) HasRaw() bool {
+	return r.RawJSON != ""
+}
+
+// Extra returns the extra activity fields read from the stored strava json.
+// The zero value is returned when no json is stored.
+func (r RaceActivity) Extra() (ActivityExtra, error) {
+	var e ActivityExtra
+	if !r.HasRaw() {
+		return e, nil
+	}
+	err := json.Unmarshal([]byte(r.RawJSON), &e)
+	return e, err
+}
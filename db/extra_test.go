@@ -0,0 +1,71 @@
+package db
+
+import "testing"
+
+func TestActivityExtra(t *testing.T) {
+	full := `{"id":1,"name":"Turkey Trot","total_elevation_gain":35.2,"average_heartrate":162.4,
+		"max_heartrate":181,"kudos_count":12,"start_latlng":[40.6,-74.05],"end_latlng":[40.61,-74.04],
+		"timezone":"(GMT-05:00) America/New_York","device_name":"Garmin Forerunner 255"}`
+	// manual activities have no heart rate and an empty route
+	partial := `{"id":2,"name":"Treadmill 5K","start_latlng":[],"end_latlng":[]}`
+
+	testCases := []struct {
+		name         string
+		raw          string
+		hasRaw       bool
+		hasHeartrate bool
+		hasStart     bool
+		device       string
+		kudos        uint32
+	}{
+		{"full", full, true, true, true, "Garmin Forerunner 255", 12},
+		{"partial", partial, true, false, false, "", 0},
+		{"empty", "", false, false, false, "", 0},
+	}
+	for _, tc := range testCases {
+		r := RaceActivity{StravaId: 1, RawJSON: tc.raw}
+		if r.HasRaw() != tc.hasRaw {
+			t.Errorf("%s: incorrect HasRaw. Found(%v), Expected(%v)", tc.name, r.HasRaw(), tc.hasRaw)
+		}
+		e, err := r.Extra()
+		if err != nil {
+			t.Errorf("%s: unexpected error. %s", tc.name, err)
+			continue
+		}
+		if e.HasHeartrate() != tc.hasHeartrate || e.DeviceName != tc.device || e.KudosCount != tc.kudos {
+			t.Errorf("%s: incorrect extra fields. Found(%+v)", tc.name, e)
+		}
+		lat, lng, ok := e.StartLocation()
+		if ok != tc.hasStart {
+			t.Errorf("%s: incorrect start location. Found(%v), Expected(%v)", tc.name, ok, tc.hasStart)
+		}
+		if ok && (lat != 40.6 || lng != -74.05) {
+			t.Errorf("%s: incorrect start location. Found(%v, %v), Expected(%v, %v)", tc.name, lat, lng, 40.6, -74.05)
+		}
+	}
+
+	if _, err := (RaceActivity{RawJSON: "{"}).Extra(); err == nil {
+		t.Errorf("Expected an error for invalid json")
+	}
+}
+
+func TestStoredActivityExtra(t *testing.T) {
+	s := newTestStore(t)
+	r := newRace(1, "Turkey Trot", "2023-11-23T08:00:00Z")
+	r.RawJSON = `{"id":1,"device_name":"Garmin Forerunner 255","average_heartrate":150}`
+	if err := s.InsertRaceActivity(r); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+
+	stored, err := s.SelectRaceActivityById(1)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	e, err := stored.Extra()
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if e.DeviceName != "Garmin Forerunner 255" || !e.HasHeartrate() {
+		t.Errorf("Incorrect extra fields of the stored race. Found(%+v)", e)
+	}
+}
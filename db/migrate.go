@@ -0,0 +1,264 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ddominguez/run-david-run/migrations"
+)
+
+// versionTable is the goose version table, so databases migrated with the
+// goose binary keep their version
+const versionTable = "goose_db_version"
+
+// Migration is a schema change of the sqlite database
+type Migration struct {
+	Version int64
+	Name    string
+	up      string
+	down    string
+}
+
+// parseMigration parses a goose sql migration named <version>_<name>.sql
+func parseMigration(file string, data []byte) (Migration, error) {
+	base := strings.TrimSuffix(path.Base(file), ".sql")
+	v, name, _ := strings.Cut(base, "_")
+	version, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || version <= 0 {
+		return Migration{}, fmt.Errorf("invalid migration version of %s", file)
+	}
+
+	_, up, ok := strings.Cut(string(data), "-- +goose Up")
+	if !ok {
+		return Migration{}, fmt.Errorf("migration %s has no up section", file)
+	}
+	up, down, _ := strings.Cut(up, "-- +goose Down")
+	return Migration{Version: version, Name: name, up: up, down: down}, nil
+}
+
+// Migrations returns the embedded migrations from oldest to newest
+func Migrations() ([]Migration, error) {
+	files, err := fs.Glob(migrations.FS, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	var res []Migration
+	for _, f := range files {
+		data, err := migrations.FS.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		m, err := parseMigration(f, data)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+	return res, nil
+}
+
+// MigrationStatus is a migration and whether it was applied to the database
+type MigrationStatus struct {
+	Migration
+	Applied bool
+}
+
+// hasVersionTable returns true when the database was ever migrated
+func (s *Store) hasVersionTable(ctx context.Context) (bool, error) {
+	var exists bool
+	q := `SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type='table' AND name=?)`
+	err := s.db.GetContext(ctx, &exists, q, versionTable)
+	return exists, err
+}
+
+// createVersionTable creates the version table the way goose does
+func (s *Store) createVersionTable(ctx context.Context) error {
+	exists, err := s.hasVersionTable(ctx)
+	if err != nil || exists {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `CREATE TABLE `+versionTable+` (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            version_id INTEGER NOT NULL,
+            is_applied INTEGER NOT NULL,
+            tstamp TIMESTAMP DEFAULT (datetime('now'))
+        );
+        INSERT INTO `+versionTable+`(version_id, is_applied) VALUES(0, 1);`)
+	return err
+}
+
+// appliedVersions returns the versions applied to the database. The newest
+// row of a version decides whether it is applied. A database without the
+// version table has no applied versions.
+func (s *Store) appliedVersions(ctx context.Context) (map[int64]bool, error) {
+	exists, err := s.hasVersionTable(ctx)
+	if err != nil || !exists {
+		return map[int64]bool{}, err
+	}
+	var rows []struct {
+		Version int64 `db:"version_id"`
+		Applied bool  `db:"is_applied"`
+	}
+	q := `SELECT version_id, is_applied FROM ` + versionTable + ` ORDER BY id DESC`
+	if err := s.db.SelectContext(ctx, &rows, q); err != nil {
+		return nil, err
+	}
+	res := map[int64]bool{}
+	seen := map[int64]bool{}
+	for _, r := range rows {
+		if seen[r.Version] || r.Version == 0 {
+			continue
+		}
+		seen[r.Version] = true
+		if r.Applied {
+			res[r.Version] = true
+		}
+	}
+	return res, nil
+}
+
+// MigrationStatus returns every embedded migration and whether it was
+// applied. It does not change the database.
+func (s *Store) MigrationStatus() ([]MigrationStatus, error) {
+	return s.MigrationStatusContext(context.Background())
+}
+
+func (s *Store) MigrationStatusContext(ctx context.Context) ([]MigrationStatus, error) {
+	all, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := s.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]MigrationStatus, len(all))
+	for i, m := range all {
+		res[i] = MigrationStatus{Migration: m, Applied: applied[m.Version]}
+	}
+	return res, nil
+}
+
+// migrate runs the up or down section of a migration and records its version
+func (s *Store) migrate(ctx context.Context, m Migration, up bool) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q := m.down
+	if up {
+		q = m.up
+	}
+	if _, err := tx.ExecContext(ctx, q); err != nil {
+		return fmt.Errorf("unable to migrate %d_%s: %w", m.Version, m.Name, err)
+	}
+	if up {
+		q = `INSERT INTO ` + versionTable + `(version_id, is_applied) VALUES(?, 1)`
+	} else {
+		q = `DELETE FROM ` + versionTable + ` WHERE version_id=?`
+	}
+	if _, err := tx.ExecContext(ctx, q, m.Version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MigrateUp applies the pending migrations from oldest to newest and
+// returns the applied migrations
+func (s *Store) MigrateUp() ([]Migration, error) {
+	return s.MigrateUpContext(context.Background())
+}
+
+func (s *Store) MigrateUpContext(ctx context.Context) ([]Migration, error) {
+	if err := s.createVersionTable(ctx); err != nil {
+		return nil, err
+	}
+	status, err := s.MigrationStatusContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var res []Migration
+	for _, m := range status {
+		if m.Applied {
+			continue
+		}
+		if err := s.migrate(ctx, m.Migration, true); err != nil {
+			return res, err
+		}
+		res = append(res, m.Migration)
+	}
+	return res, nil
+}
+
+// MigrateDown rolls back the newest applied migration. It returns false
+// when no migration is applied.
+func (s *Store) MigrateDown() (Migration, bool, error) {
+	return s.MigrateDownContext(context.Background())
+}
+
+func (s *Store) MigrateDownContext(ctx context.Context) (Migration, bool, error) {
+	status, err := s.MigrationStatusContext(ctx)
+	if err != nil {
+		return Migration{}, false, err
+	}
+	for i := len(status) - 1; i >= 0; i-- {
+		if !status[i].Applied {
+			continue
+		}
+		m := status[i].Migration
+		return m, true, s.migrate(ctx, m, false)
+	}
+	return Migration{}, false, nil
+}
+
+// CheckVersion returns an error when a migration was not applied to the
+// database, or when the database was migrated by a newer build
+func (s *Store) CheckVersion() error {
+	return s.CheckVersionContext(context.Background())
+}
+
+func (s *Store) CheckVersionContext(ctx context.Context) error {
+	exists, err := s.hasVersionTable(ctx)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("database %s has no schema version, run `races migrate up` to create it", s.dsn)
+	}
+
+	status, err := s.MigrationStatusContext(ctx)
+	if err != nil {
+		return err
+	}
+	var pending []string
+	known := map[int64]bool{}
+	for _, m := range status {
+		known[m.Version] = true
+		if !m.Applied {
+			pending = append(pending, fmt.Sprintf("%d_%s", m.Version, m.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database %s is missing migrations %s, run `races migrate up` to apply them",
+			s.dsn, strings.Join(pending, ", "))
+	}
+
+	applied, err := s.appliedVersions(ctx)
+	if err != nil {
+		return err
+	}
+	for v := range applied {
+		if !known[v] {
+			return fmt.Errorf("database %s has migration %d which this build does not know, update races", s.dsn, v)
+		}
+	}
+	return nil
+}
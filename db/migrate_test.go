@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseMigration(t *testing.T) {
+	data := []byte("-- +goose Up\nCREATE TABLE a (id INTEGER);\n-- +goose Down\nDROP TABLE a;\n")
+	m, err := parseMigration("20261017150000_add_a.sql", data)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if m.Version != 20261017150000 || m.Name != "add_a" {
+		t.Errorf("Incorrect migration. Found(%d %s), Expected(%d %s)", m.Version, m.Name, 20261017150000, "add_a")
+	}
+	if !strings.Contains(m.up, "CREATE TABLE") || strings.Contains(m.up, "DROP TABLE") {
+		t.Errorf("Incorrect up section. Found(%q)", m.up)
+	}
+	if !strings.Contains(m.down, "DROP TABLE") {
+		t.Errorf("Incorrect down section. Found(%q)", m.down)
+	}
+
+	tests := []struct {
+		file string
+		data string
+	}{
+		{"add_a.sql", "-- +goose Up\n"},
+		{"0_add_a.sql", "-- +goose Up\n"},
+		{"20261017150000_add_a.sql", "CREATE TABLE a (id INTEGER);"},
+	}
+	for _, test := range tests {
+		if _, err := parseMigration(test.file, []byte(test.data)); err == nil {
+			t.Errorf("Expected an error for %s", test.file)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	s, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	defer s.Close()
+	all, err := Migrations()
+	if err != nil || len(all) == 0 {
+		t.Fatalf("Expected embedded migrations. Found(%d, %v)", len(all), err)
+	}
+
+	if err := s.CheckVersion(); err == nil || !strings.Contains(err.Error(), "migrate up") {
+		t.Errorf("Expected an error for a database without a version. Found(%v)", err)
+	}
+
+	// the status of a new database is read only
+	status, err := s.MigrationStatus()
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if len(status) != len(all) || status[0].Applied {
+		t.Errorf("Expected every migration to be pending. Found(%+v)", status)
+	}
+	if exists, err := s.hasVersionTable(context.Background()); err != nil || exists {
+		t.Errorf("Expected the status to not create the version table. Found(%v, %v)", exists, err)
+	}
+
+	applied, err := s.MigrateUp()
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if len(applied) != len(all) {
+		t.Errorf("Incorrect number of applied migrations. Found(%d), Expected(%d)", len(applied), len(all))
+	}
+	if err := s.CheckVersion(); err != nil {
+		t.Errorf("Unexpected error for a migrated database. %s", err)
+	}
+
+	latest := all[len(all)-1]
+	m, ok, err := s.MigrateDown()
+	if err != nil || !ok {
+		t.Fatalf("Unexpected rollback. Found(%v, %v)", ok, err)
+	}
+	if m.Version != latest.Version {
+		t.Errorf("Incorrect rolled back migration. Found(%d), Expected(%d)", m.Version, latest.Version)
+	}
+	err = s.CheckVersion()
+	if err == nil || !strings.Contains(err.Error(), latest.Name) {
+		t.Errorf("Expected an error naming the pending migration. Found(%v)", err)
+	}
+
+	status, err = s.MigrationStatus()
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	for i, m := range status {
+		if expected := i < len(status)-1; m.Applied != expected {
+			t.Errorf("Incorrect status of %d. Found(%v), Expected(%v)", m.Version, m.Applied, expected)
+		}
+	}
+
+	applied, err = s.MigrateUp()
+	if err != nil || len(applied) != 1 {
+		t.Errorf("Expected only the rolled back migration to be applied. Found(%d, %v)", len(applied), err)
+	}
+}
+
+func TestCheckVersionUnknownMigration(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.db.Exec(`INSERT INTO `+versionTable+`(version_id, is_applied) VALUES(?, 1)`, 99991231000000); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if err := s.CheckVersion(); err == nil || !strings.Contains(err.Error(), "99991231000000") {
+		t.Errorf("Expected an error for a newer database. Found(%v)", err)
+	}
+}
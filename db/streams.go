@@ -0,0 +1,188 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/ddominguez/run-david-run/streams"
+)
+
+// RaceStreams holds the recorded data points of a race activity.
+// Streams that were not recorded are empty.
+type RaceStreams struct {
+	LatLng         [][2]float64
+	Time           []int
+	Distance       []float64
+	Altitude       []float64
+	Heartrate      []int
+	Cadence        []int
+	VelocitySmooth []float64
+}
+
+// IsEmpty returns true when no stream has data points
+func (s RaceStreams) IsEmpty() bool {
+	return len(s.LatLng) == 0 && len(s.Time) == 0 && len(s.Distance) == 0 &&
+		len(s.Altitude) == 0 && len(s.Heartrate) == 0 && len(s.Cadence) == 0 &&
+		len(s.VelocitySmooth) == 0
+}
+
+// streamPrecision is the number of decimals stored for each stream type
+var streamPrecision = map[string]int{
+	"latlng":          6,
+	"time":            0,
+	"distance":        1,
+	"altitude":        1,
+	"heartrate":       0,
+	"cadence":         0,
+	"velocity_smooth": 3,
+}
+
+func intsToFloats(v []int) []float64 {
+	res := make([]float64, len(v))
+	for i := range v {
+		res[i] = float64(v[i])
+	}
+	return res
+}
+
+func floatsToInts(v []float64) []int {
+	res := make([]int, len(v))
+	for i := range v {
+		res[i] = int(math.Round(v[i]))
+	}
+	return res
+}
+
+// flatStream is a stream as a flat list of values with dims values per data point
+type flatStream struct {
+	values []float64
+	dims   int
+}
+
+// flat returns every non empty stream by stream type
+func (s RaceStreams) flat() map[string]flatStream {
+	res := map[string]flatStream{}
+	add := func(t string, values []float64, dims int) {
+		if len(values) > 0 {
+			res[t] = flatStream{values, dims}
+		}
+	}
+
+	latlng := make([]float64, 0, len(s.LatLng)*2)
+	for _, p := range s.LatLng {
+		latlng = append(latlng, p[0], p[1])
+	}
+	add("latlng", latlng, 2)
+	add("time", intsToFloats(s.Time), 1)
+	add("distance", s.Distance, 1)
+	add("altitude", s.Altitude, 1)
+	add("heartrate", intsToFloats(s.Heartrate), 1)
+	add("cadence", intsToFloats(s.Cadence), 1)
+	add("velocity_smooth", s.VelocitySmooth, 1)
+	return res
+}
+
+// noStreams is the stream type of the row saved for a race activity
+// without streams, so the streams are not requested again
+const noStreams = "none"
+
+// SaveRaceStreams replaces the streams of a race activity.
+// Empty streams are saved as a row of type noStreams.
+func (s *Store) SaveRaceStreams(stravaId uint64, rs RaceStreams) error {
+	return s.SaveRaceStreamsContext(context.Background(), stravaId, rs)
+}
+
+func (s *Store) SaveRaceStreamsContext(ctx context.Context, stravaId uint64, rs RaceStreams) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM race_stream WHERE strava_id=?", stravaId); err != nil {
+		return err
+	}
+	if rs.IsEmpty() {
+		q := `INSERT INTO race_stream(strava_id, stream_type, data) VALUES(?, ?, ?)`
+		if _, err := tx.ExecContext(ctx, q, stravaId, noStreams, []byte{}); err != nil {
+			return err
+		}
+	}
+	for t, v := range rs.flat() {
+		data, err := streams.Encode(v.values, v.dims, streamPrecision[t])
+		if err != nil {
+			return fmt.Errorf("unable to encode %s stream: %w", t, err)
+		}
+		q := `INSERT INTO race_stream(strava_id, stream_type, data) VALUES(?, ?, ?)`
+		if _, err := tx.ExecContext(ctx, q, stravaId, t, data); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// HasRaceStreams returns true when streams are saved for a race activity,
+// or it is known to have no streams
+func (s *Store) HasRaceStreams(stravaId uint64) (bool, error) {
+	return s.HasRaceStreamsContext(context.Background(), stravaId)
+}
+
+func (s *Store) HasRaceStreamsContext(ctx context.Context, stravaId uint64) (bool, error) {
+	var exists bool
+	err := s.db.GetContext(ctx, &exists, "SELECT EXISTS(SELECT 1 FROM race_stream WHERE strava_id=?)", stravaId)
+	if err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// SelectRaceStreams returns the saved streams of a race activity
+func (s *Store) SelectRaceStreams(stravaId uint64) (RaceStreams, error) {
+	return s.SelectRaceStreamsContext(context.Background(), stravaId)
+}
+
+func (s *Store) SelectRaceStreamsContext(ctx context.Context, stravaId uint64) (RaceStreams, error) {
+	var res RaceStreams
+	var rows []struct {
+		StreamType string `db:"stream_type"`
+		Data       []byte `db:"data"`
+	}
+	err := s.db.SelectContext(ctx, &rows, "SELECT stream_type, data FROM race_stream WHERE strava_id=?", stravaId)
+	if err != nil {
+		return res, err
+	}
+
+	for _, row := range rows {
+		if row.StreamType == noStreams {
+			continue
+		}
+		values, dims, err := streams.Decode(row.Data)
+		if err != nil {
+			return res, fmt.Errorf("unable to decode %s stream of %d: %w", row.StreamType, stravaId, err)
+		}
+		switch row.StreamType {
+		case "latlng":
+			if dims != 2 {
+				return res, fmt.Errorf("latlng stream of %d has %d dims", stravaId, dims)
+			}
+			res.LatLng = make([][2]float64, len(values)/2)
+			for i := range res.LatLng {
+				res.LatLng[i] = [2]float64{values[i*2], values[i*2+1]}
+			}
+		case "time":
+			res.Time = floatsToInts(values)
+		case "distance":
+			res.Distance = values
+		case "altitude":
+			res.Altitude = values
+		case "heartrate":
+			res.Heartrate = floatsToInts(values)
+		case "cadence":
+			res.Cadence = floatsToInts(values)
+		case "velocity_smooth":
+			res.VelocitySmooth = values
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,174 @@
+// Package events groups the races of several athletes that were run at the
+// same event
+package events
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ddominguez/run-david-run/db"
+	"github.com/ddominguez/run-david-run/polyline"
+)
+
+const (
+	// distanceTolerance is how much the distance of two races of the same
+	// event may differ, as a fraction of the longer race
+	distanceTolerance = 0.05
+	// startRadius in meters is how far apart the starts of two races of the
+	// same event may be, e.g. because of start corrals or gps drift
+	startRadius = 1000
+)
+
+// Result is the race of an athlete at an event
+type Result struct {
+	Race db.RaceActivity
+	// Place is the placing among the athletes of the event by elapsed
+	// time, athletes with the same time share a place
+	Place int
+}
+
+// Event is a race run by more than one athlete
+type Event struct {
+	// Key identifies the event, e.g. 2024-06-23-queens-10k
+	Key     string
+	Name    string
+	Date    time.Time
+	Results []Result
+}
+
+// race is a race with what it is matched on
+type race struct {
+	db.RaceActivity
+	date     string
+	start    polyline.Point
+	hasStart bool
+}
+
+func newRace(a db.RaceActivity) (race, error) {
+	t, err := time.Parse(time.RFC3339, string(a.StartDate))
+	if err != nil {
+		return race{}, fmt.Errorf("invalid start date of %d: %w", a.StravaId, err)
+	}
+	r := race{RaceActivity: a, date: t.Format(time.DateOnly)}
+	// races without a valid route are matched on date and distance
+	if points, err := polyline.Decode(a.Polyline); err == nil && len(points) > 0 {
+		r.start, r.hasStart = points[0], true
+	}
+	return r, nil
+}
+
+// matches returns true when two races are on the same date, of about the
+// same distance and, when both have a route, start at about the same place
+func (r race) matches(other race) bool {
+	if r.date != other.date || r.SportType != other.SportType {
+		return false
+	}
+	longer := math.Max(r.Distance, other.Distance)
+	if longer == 0 || math.Abs(r.Distance-other.Distance) > longer*distanceTolerance {
+		return false
+	}
+	if r.hasStart && other.hasStart {
+		return polyline.Distance(r.start, other.start) <= startRadius
+	}
+	return true
+}
+
+// group are the races of an event, at most one per athlete
+type group struct {
+	races []race
+}
+
+func (g *group) matches(r race) bool {
+	for _, other := range g.races {
+		if other.AthleteId == r.AthleteId || !other.matches(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// name returns the most common race name of the group, athletes name their
+// activities differently, the earliest race wins a tie
+func (g *group) name() string {
+	counts := map[string]int{}
+	var best string
+	for _, r := range g.races {
+		counts[r.Name]++
+		if counts[r.Name] > counts[best] {
+			best = r.Name
+		}
+	}
+	return best
+}
+
+var slugRe = regexp.MustCompile("[^a-z0-9]+")
+
+// results returns the races by elapsed time with their placing
+func (g *group) results() []Result {
+	res := make([]Result, len(g.races))
+	for i, r := range g.races {
+		res[i] = Result{Race: r.RaceActivity}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Race.ElapsedTime < res[j].Race.ElapsedTime })
+	for i := range res {
+		res[i].Place = i + 1
+		if i > 0 && res[i].Race.ElapsedTime == res[i-1].Race.ElapsedTime {
+			res[i].Place = res[i-1].Place
+		}
+	}
+	return res
+}
+
+// Group returns the events of activities run by more than one athlete,
+// newest first. A race belongs to the first event on its date whose races
+// all match it.
+func Group(activities []db.RaceActivity) ([]Event, error) {
+	races := make([]race, 0, len(activities))
+	for _, a := range activities {
+		r, err := newRace(a)
+		if err != nil {
+			return nil, err
+		}
+		races = append(races, r)
+	}
+	sort.SliceStable(races, func(i, j int) bool { return races[i].StartDate < races[j].StartDate })
+
+	var groups []*group
+	for _, r := range races {
+		var found *group
+		for _, g := range groups {
+			if g.matches(r) {
+				found = g
+				break
+			}
+		}
+		if found == nil {
+			found = &group{}
+			groups = append(groups, found)
+		}
+		found.races = append(found.races, r)
+	}
+
+	var res []Event
+	keys := map[string]int{}
+	for i := len(groups) - 1; i >= 0; i-- {
+		g := groups[i]
+		if len(g.races) < 2 {
+			continue
+		}
+		date, _ := time.Parse(time.DateOnly, g.races[0].date)
+		name := g.name()
+		key := g.races[0].date + "-" + strings.Trim(slugRe.ReplaceAllString(strings.ToLower(name), "-"), "-")
+		// events of the same name on the same date, e.g. a 5k and a 10k
+		keys[key]++
+		if n := keys[key]; n > 1 {
+			key = fmt.Sprintf("%s-%d", key, n)
+		}
+		res = append(res, Event{Key: key, Name: name, Date: date, Results: g.results()})
+	}
+	return res, nil
+}
@@ -0,0 +1,96 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/ddominguez/run-david-run/db"
+	"github.com/ddominguez/run-david-run/polyline"
+)
+
+// route returns a polyline of a route starting at lat, lng
+func route(lat, lng float64) string {
+	return polyline.Encode([]polyline.Point{{Lat: lat, Lng: lng}, {Lat: lat + 0.01, Lng: lng}})
+}
+
+func newActivity(id, athleteId uint64, name, start string, distance float64, elapsed uint32, line string) db.RaceActivity {
+	return db.RaceActivity{
+		StravaId:    id,
+		AthleteId:   athleteId,
+		Name:        name,
+		SportType:   "Run",
+		Distance:    distance,
+		ElapsedTime: elapsed,
+		StartDate:   db.DateTime(start),
+		Polyline:    line,
+	}
+}
+
+func TestGroup(t *testing.T) {
+	queens := route(40.7498, -73.8408)
+	activities := []db.RaceActivity{
+		newActivity(1, 1, "Queens 10K", "2024-06-23T08:00:00Z", 10050, 2510, queens),
+		newActivity(2, 2, "Queens 10K", "2024-06-23T08:02:00Z", 10010, 2420, route(40.7502, -73.8400)),
+		// no route, matched on date and distance
+		newActivity(3, 3, "Morning Run", "2024-06-23T08:05:00Z", 9990, 2510, ""),
+		// same day and place, another distance
+		newActivity(4, 4, "Queens 5K", "2024-06-23T08:30:00Z", 5000, 1500, queens),
+		// same day and distance, another city
+		newActivity(5, 5, "Bronx 10K", "2024-06-23T08:00:00Z", 10000, 2600, route(40.8448, -73.8648)),
+		// another day
+		newActivity(6, 2, "Queens 10K", "2023-06-25T08:00:00Z", 10000, 2500, queens),
+		newActivity(7, 1, "Queens 10K", "2023-06-25T08:00:00Z", 10020, 2550, queens),
+		// the same athlete is only once in an event
+		newActivity(8, 1, "Queens 10K Again", "2023-06-25T10:00:00Z", 10020, 2700, queens),
+	}
+
+	events, err := Group(activities)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Incorrect number of events. Found(%d), Expected(%d)", len(events), 2)
+	}
+
+	e := events[0]
+	if e.Key != "2024-06-23-queens-10k" || e.Name != "Queens 10K" {
+		t.Errorf("Incorrect event. Found(%s %s), Expected(%s %s)", e.Key, e.Name, "2024-06-23-queens-10k", "Queens 10K")
+	}
+	tests := []struct {
+		id    uint64
+		place int
+	}{
+		{2, 1},
+		{1, 2},
+		{3, 2},
+	}
+	if len(e.Results) != len(tests) {
+		t.Fatalf("Incorrect number of results. Found(%d), Expected(%d)", len(e.Results), len(tests))
+	}
+	for i, test := range tests {
+		r := e.Results[i]
+		if r.Race.StravaId != test.id || r.Place != test.place {
+			t.Errorf("Incorrect result %d. Found(%d, %d), Expected(%d, %d)", i, r.Race.StravaId, r.Place, test.id, test.place)
+		}
+	}
+
+	e = events[1]
+	if e.Key != "2023-06-25-queens-10k" || len(e.Results) != 2 {
+		t.Errorf("Incorrect event. Found(%s with %d results), Expected(%s with %d results)", e.Key, len(e.Results), "2023-06-25-queens-10k", 2)
+	}
+}
+
+func TestGroupSameNameKeys(t *testing.T) {
+	activities := []db.RaceActivity{
+		newActivity(1, 1, "Turkey Trot", "2023-11-23T08:00:00Z", 5000, 1500, ""),
+		newActivity(2, 2, "Turkey Trot", "2023-11-23T08:00:00Z", 5010, 1400, ""),
+		newActivity(3, 1, "Turkey Trot", "2023-11-23T09:00:00Z", 10000, 3000, ""),
+		newActivity(4, 2, "Turkey Trot", "2023-11-23T09:00:00Z", 10010, 2900, ""),
+	}
+	events, err := Group(activities)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if len(events) != 2 || events[0].Key == events[1].Key {
+		t.Errorf("Expected two events with different keys. Found(%+v)", events)
+	}
+}
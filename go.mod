@@ -3,9 +3,11 @@ module github.com/ddominguez/run-david-run
 go 1.22
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/jmoiron/sqlx v1.3.5
 	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/spf13/cobra v1.8.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
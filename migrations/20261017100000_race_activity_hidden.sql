@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE race_activity ADD COLUMN hidden_at TEXT NOT NULL DEFAULT '';
+ALTER TABLE race_activity ADD COLUMN hidden_reason TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE race_activity DROP COLUMN hidden_reason;
+ALTER TABLE race_activity DROP COLUMN hidden_at;
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE race_activity ADD COLUMN sport_type TEXT NOT NULL DEFAULT 'Run';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE race_activity DROP COLUMN sport_type;
+-- +goose StatementEnd
@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE race_split (
+    strava_id INTEGER NOT NULL,
+    kind TEXT NOT NULL,
+    split INTEGER NOT NULL,
+    distance REAL DEFAULT 0.0,
+    elapsed_time INTEGER DEFAULT 0,
+    moving_time INTEGER DEFAULT 0,
+    elevation_difference REAL DEFAULT 0.0,
+    average_speed REAL DEFAULT 0.0,
+    average_heartrate REAL DEFAULT 0.0,
+    PRIMARY KEY (strava_id, kind, split)
+);
+
+CREATE TABLE race_lap (
+    strava_id INTEGER NOT NULL,
+    lap_index INTEGER NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    distance REAL DEFAULT 0.0,
+    elapsed_time INTEGER DEFAULT 0,
+    moving_time INTEGER DEFAULT 0,
+    total_elevation_gain REAL DEFAULT 0.0,
+    average_speed REAL DEFAULT 0.0,
+    average_heartrate REAL DEFAULT 0.0,
+    PRIMARY KEY (strava_id, lap_index)
+);
+
+CREATE TABLE race_best_effort (
+    strava_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    distance REAL DEFAULT 0.0,
+    elapsed_time INTEGER DEFAULT 0,
+    moving_time INTEGER DEFAULT 0,
+    pr_rank INTEGER DEFAULT 0,
+    PRIMARY KEY (strava_id, name)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE race_split;
+DROP TABLE race_lap;
+DROP TABLE race_best_effort;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE race_stream (
+    strava_id INTEGER NOT NULL,
+    stream_type TEXT NOT NULL,
+    data BLOB NOT NULL,
+    PRIMARY KEY (strava_id, stream_type)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE race_stream;
+-- +goose StatementEnd
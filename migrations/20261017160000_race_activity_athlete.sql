@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX race_activity_athlete ON race_activity(strava_athlete_id, start_date_local);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX race_activity_athlete;
+-- +goose StatementEnd
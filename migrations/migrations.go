@@ -0,0 +1,9 @@
+// Package migrations embeds the sql migrations of the sqlite database.
+// Migrations use the goose format, an up and a down section per file
+// named after the version, e.g. 20261017150000_race_activity_category.sql
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
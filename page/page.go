@@ -4,6 +4,8 @@ import (
 	"html/template"
 	"io"
 	"os"
+
+	"github.com/ddominguez/run-david-run/db"
 )
 
 type Tmpl struct {
@@ -30,12 +32,218 @@ func New(files []string) *Tmpl {
 	}
 }
 
+// Site is the site of a page, the site of an athlete or the club page
+type Site struct {
+	Title string
+	// Name is the first name of the athlete of the site
+	Name string
+	// Url is the url of the index page of the site
+	Url string
+	// ClubUrl and ClubName link back to the club page, only set when
+	// the site is one of several athletes
+	ClubUrl  string
+	ClubName string
+}
+
 type RaceData struct {
+	Site      Site
 	Name      string
 	StartDate string
+	SetPR     bool
 	Distance  string
+	// PaceLabel is Pace, or Speed for sports measured by speed
+	PaceLabel string
 	Pace      string
 	Time      string
-	MapboxUrl string
-	StaticUrl string
+	Units     string
+	// UnitLinks switch the units of the page, only set by the server
+	UnitLinks   []Filter
+	MapboxUrl   string
+	MapSVG      template.HTML
+	StaticUrl   string
+	Splits      []SplitData
+	BestEfforts []BestEffortData
+	Charts      []ChartData
+	// Event links to the page of the event when other athletes ran the race
+	Event RaceLink
+}
+
+// SplitData is a row of the race splits table
+type SplitData struct {
+	Split     uint16
+	Distance  string
+	Pace      string
+	Time      string
+	Elevation string
+}
+
+// BestEffortData is an item of the race best efforts list
+type BestEffortData struct {
+	Name   string
+	Time   string
+	Pace   string
+	PrRank uint8
+}
+
+// ChartData is an svg chart of the race page
+type ChartData struct {
+	Title string
+	SVG   template.HTML
+}
+
+// RecordData is a personal record, or a race of its progression
+type RecordData struct {
+	Distance    string
+	Time        string
+	Pace        string
+	Name        string
+	Url         string
+	Date        string
+	Progression []RecordData
+}
+
+// SportRecordsData are the personal records of a sport
+type SportRecordsData struct {
+	Sport string
+	// PaceLabel is Pace or Speed, the column of the records
+	PaceLabel string
+	Records   []RecordData
+}
+
+// RecordsData is the data of the personal records page
+type RecordsData struct {
+	Site   Site
+	Sports []SportRecordsData
+	// UnitLinks switch the units of the page, only set by the server
+	UnitLinks []Filter
+}
+
+// RaceLink is a link to a race page
+type RaceLink struct {
+	Name string
+	Url  string
+	Date string
+}
+
+// MixData is the share of the races of a year at a race distance
+type MixData struct {
+	Name    string
+	Count   int
+	Percent int
+}
+
+// MonthData are the races of a month of the year calendar
+type MonthData struct {
+	Name  string
+	Races []RaceLink
+}
+
+// YearData is the data of a year in review page
+type YearData struct {
+	Site        Site
+	Year        int
+	Count       int
+	Distance    string
+	Time        string
+	FastestPace string
+	Fastest     RaceLink
+	Mix         []MixData
+	Months      []MonthData
+	// Years link to the pages of every year with races
+	Years []Filter
+	// UnitLinks switch the units of the page, only set by the server
+	UnitLinks []Filter
+}
+
+// MemberData is an athlete listed on the club page
+type MemberData struct {
+	Name    string
+	Profile string
+	Url     string
+	Races   int
+	Records int
+}
+
+// ClubRaceData is a race of an athlete listed on the club page
+type ClubRaceData struct {
+	RaceLink
+	Year    int
+	Athlete string
+}
+
+// ClubData is the data of the club page listing the races of every athlete
+type ClubData struct {
+	Site    Site
+	Members []MemberData
+	// Events are the races run by several athletes, newest first
+	Events []RaceLink
+	// Races are the races of every athlete, newest first
+	Races []ClubRaceData
+}
+
+// EventResultData is the result of an athlete at an event
+type EventResultData struct {
+	Place      int
+	Athlete    string
+	AthleteUrl string
+	Time       string
+	Pace       string
+	Url        string
+}
+
+// EventData is the data of the page of a race run by several athletes
+type EventData struct {
+	Site      Site
+	Name      string
+	Date      string
+	Distance  string
+	PaceLabel string
+	Results   []EventResultData
+	// UnitLinks switch the units of the page, only set by the server
+	UnitLinks []Filter
+}
+
+// Filter is a link to a filtered index page
+type Filter struct {
+	Key    string
+	Name   string
+	Url    string
+	Active bool
+}
+
+type IndexData struct {
+	Site        Site
+	Activities  []db.RaceActivity
+	IsGenerated bool
+	AllUrl      string
+	Sports      []Filter
+	Categories  []Filter
+	RecordsUrl  string
+	// PRs are the ids of the races that set a personal record
+	PRs map[uint64]bool
+}
+
+// HasSportFilter returns true when the races are from more than one sport
+func (d IndexData) HasSportFilter() bool {
+	return len(d.Sports) > 1
+}
+
+// HasCategoryFilter returns true when the races are from more than one category
+func (d IndexData) HasCategoryFilter() bool {
+	return len(d.Categories) > 1
+}
+
+// HasFilters returns true when the races can be filtered
+func (d IndexData) HasFilters() bool {
+	return d.HasSportFilter() || d.HasCategoryFilter()
+}
+
+// IsFiltered returns true when only part of the races are listed
+func (d IndexData) IsFiltered() bool {
+	for _, f := range append(d.Sports, d.Categories...) {
+		if f.Active {
+			return true
+		}
+	}
+	return false
 }
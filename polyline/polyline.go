@@ -0,0 +1,292 @@
+// Package polyline encodes and decodes routes in the google encoded
+// polyline format used by strava for activity maps, and has helpers to
+// measure, simplify and trim routes.
+package polyline
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrInvalid is returned when a polyline can not be decoded
+var ErrInvalid = errors.New("invalid encoded polyline")
+
+// Point is a latitude and longitude in degrees
+type Point struct {
+	Lat float64
+	Lng float64
+}
+
+// precision is the factor of the 5 decimals kept by the format
+const precision = 1e5
+
+// decodeValue decodes the variable length value starting at s[i]
+// and returns it with the index of the next value.
+func decodeValue(s string, i int) (int, int, error) {
+	var res, shift int
+	for {
+		if i >= len(s) {
+			return 0, i, ErrInvalid
+		}
+		b := int(s[i]) - 63
+		i++
+		if b < 0 || b > 63 {
+			return 0, i, ErrInvalid
+		}
+		res |= (b & 0x1f) << shift
+		shift += 5
+		if b < 0x20 {
+			break
+		}
+		if shift > 30 {
+			return 0, i, ErrInvalid
+		}
+	}
+	if res&1 != 0 {
+		return ^(res >> 1), i, nil
+	}
+	return res >> 1, i, nil
+}
+
+// encodeValue appends the variable length encoding of v
+func encodeValue(b []byte, v int) []byte {
+	u := v << 1
+	if v < 0 {
+		u = ^u
+	}
+	for u >= 0x20 {
+		b = append(b, byte((0x20|(u&0x1f))+63))
+		u >>= 5
+	}
+	return append(b, byte(u+63))
+}
+
+// Encode returns the encoded polyline of the points, rounded to 5 decimals
+func Encode(points []Point) string {
+	var b []byte
+	var lat, lng int
+	for _, p := range points {
+		plat := int(math.Round(p.Lat * precision))
+		plng := int(math.Round(p.Lng * precision))
+		b = encodeValue(b, plat-lat)
+		b = encodeValue(b, plng-lng)
+		lat, lng = plat, plng
+	}
+	return string(b)
+}
+
+// Decode returns the points of an encoded polyline
+func Decode(s string) ([]Point, error) {
+	var res []Point
+	var lat, lng int
+	for i := 0; i < len(s); {
+		var dlat, dlng int
+		var err error
+		if dlat, i, err = decodeValue(s, i); err != nil {
+			return nil, err
+		}
+		if dlng, i, err = decodeValue(s, i); err != nil {
+			return nil, err
+		}
+		lat += dlat
+		lng += dlng
+		res = append(res, Point{float64(lat) / precision, float64(lng) / precision})
+	}
+	return res, nil
+}
+
+// earthRadius is the mean earth radius in meters
+const earthRadius = 6371008.8
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// Distance returns the great circle distance between two points in meters
+func Distance(a, b Point) float64 {
+	dLat := radians(b.Lat - a.Lat)
+	dLng := radians(b.Lng - a.Lng)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(radians(a.Lat))*math.Cos(radians(b.Lat))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Length returns the length of the route in meters
+func Length(points []Point) float64 {
+	var res float64
+	for i := 1; i < len(points); i++ {
+		res += Distance(points[i-1], points[i])
+	}
+	return res
+}
+
+// Bounds is the bounding box of a route
+type Bounds struct {
+	Min Point
+	Max Point
+}
+
+// Contains returns true when the point is inside the bounding box
+func (b Bounds) Contains(p Point) bool {
+	return p.Lat >= b.Min.Lat && p.Lat <= b.Max.Lat && p.Lng >= b.Min.Lng && p.Lng <= b.Max.Lng
+}
+
+// BoundingBox returns the smallest box containing every point.
+// It returns false when there are no points.
+func BoundingBox(points []Point) (Bounds, bool) {
+	if len(points) == 0 {
+		return Bounds{}, false
+	}
+	b := Bounds{points[0], points[0]}
+	for _, p := range points[1:] {
+		b.Min.Lat, b.Max.Lat = math.Min(b.Min.Lat, p.Lat), math.Max(b.Max.Lat, p.Lat)
+		b.Min.Lng, b.Max.Lng = math.Min(b.Min.Lng, p.Lng), math.Max(b.Max.Lng, p.Lng)
+	}
+	return b, true
+}
+
+// segmentDistance returns the distance in meters from p to the segment
+// a-b, measured on a flat plane around a, which is accurate for the short
+// segments of a route.
+func segmentDistance(p, a, b Point) float64 {
+	k := math.Cos(radians(a.Lat))
+	px, py := radians(p.Lng-a.Lng)*k*earthRadius, radians(p.Lat-a.Lat)*earthRadius
+	bx, by := radians(b.Lng-a.Lng)*k*earthRadius, radians(b.Lat-a.Lat)*earthRadius
+
+	l := bx*bx + by*by
+	if l == 0 {
+		return math.Hypot(px, py)
+	}
+	t := math.Max(0, math.Min(1, (px*bx+py*by)/l))
+	return math.Hypot(px-t*bx, py-t*by)
+}
+
+// Simplify removes the points that are closer than tolerance meters to the
+// simplified route with the Douglas-Peucker algorithm. The first and last
+// points are always kept.
+func Simplify(points []Point, tolerance float64) []Point {
+	if len(points) < 3 || tolerance <= 0 {
+		return points
+	}
+
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+	stack := [][2]int{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		first, last := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+
+		index, max := 0, 0.0
+		for i := first + 1; i < last; i++ {
+			if d := segmentDistance(points[i], points[first], points[last]); d > max {
+				index, max = i, d
+			}
+		}
+		if max > tolerance {
+			keep[index] = true
+			stack = append(stack, [2]int{first, index}, [2]int{index, last})
+		}
+	}
+
+	res := make([]Point, 0, len(points))
+	for i, p := range points {
+		if keep[i] {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// Zone is a circular privacy zone, e.g. around a home address
+type Zone struct {
+	Center Point
+	// Radius in meters
+	Radius float64
+}
+
+// Contains returns true when the point is inside the zone
+func (z Zone) Contains(p Point) bool {
+	return Distance(z.Center, p) <= z.Radius
+}
+
+func inZones(p Point, zones []Zone) bool {
+	for _, z := range zones {
+		if z.Contains(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// zonesRange returns the range of points left after TrimZones
+func zonesRange(points []Point, zones []Zone) (int, int) {
+	first, last := 0, len(points)
+	for first < last && inZones(points[first], zones) {
+		first++
+	}
+	for last > first && inZones(points[last-1], zones) {
+		last--
+	}
+	return first, last
+}
+
+// TrimZones removes the start and the end of the route while they are
+// inside one of the privacy zones, so the route doesn't show where it
+// started or finished. The returned route is empty when every point is
+// inside a zone.
+func TrimZones(points []Point, zones ...Zone) []Point {
+	first, last := zonesRange(points, zones)
+	return points[first:last]
+}
+
+// endsRange returns the range of points left after TrimEnds
+func endsRange(points []Point, meters float64) (int, int) {
+	if meters <= 0 || len(points) == 0 {
+		return 0, len(points)
+	}
+	first, last := 0, len(points)-1
+	for d := 0.0; first < last && d < meters; first++ {
+		d += Distance(points[first], points[first+1])
+	}
+	for d := 0.0; last > first && d < meters; last-- {
+		d += Distance(points[last-1], points[last])
+	}
+	if first >= last {
+		return 0, 0
+	}
+	return first, last + 1
+}
+
+// TrimEnds removes the first and last meters of the route, a privacy
+// option for routes that start or finish at home. The returned route is
+// empty when it is not longer than twice the trimmed distance.
+func TrimEnds(points []Point, meters float64) []Point {
+	first, last := endsRange(points, meters)
+	return points[first:last]
+}
+
+// Privacy hides the parts of a route that should not be published
+type Privacy struct {
+	Zones []Zone
+	// HideEnds is the distance in meters hidden at the start and the end
+	HideEnds float64
+}
+
+// IsEmpty returns true when nothing is hidden
+func (p Privacy) IsEmpty() bool {
+	return len(p.Zones) == 0 && p.HideEnds <= 0
+}
+
+// Apply returns the route without the points inside the privacy zones at
+// its start and end and without the hidden ends, along with the distance
+// in meters from the original start to the first returned point.
+func (p Privacy) Apply(points []Point) ([]Point, float64) {
+	first, last := zonesRange(points, p.Zones)
+	trimmed := points[first:last]
+	endsFirst, endsLast := endsRange(trimmed, p.HideEnds)
+	if endsLast == 0 {
+		return nil, 0
+	}
+	first += endsFirst
+	return points[first : first+endsLast-endsFirst], Length(points[:first+1])
+}
@@ -0,0 +1,249 @@
+package polyline
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func equalPoints(a, b []Point, tolerance float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i].Lat-b[i].Lat) > tolerance || math.Abs(a[i].Lng-b[i].Lng) > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDecode(t *testing.T) {
+	testCases := []struct {
+		name     string
+		encoded  string
+		expected []Point
+	}{
+		{"empty", "", nil},
+		{"single point", "_p~iF~ps|U", []Point{{38.5, -120.2}}},
+		// example from the google encoded polyline algorithm format documentation
+		{"google example", "_p~iF~ps|U_ulLnnqC_mqNvxq`@", []Point{{38.5, -120.2}, {40.7, -120.95}, {43.252, -126.453}}},
+		{"zero", "??", []Point{{0, 0}}},
+		{"repeated point", "_p~iF~ps|U??", []Point{{38.5, -120.2}, {38.5, -120.2}}},
+	}
+	for _, tc := range testCases {
+		res, err := Decode(tc.encoded)
+		if err != nil {
+			t.Errorf("%s: unexpected error. %s", tc.name, err)
+			continue
+		}
+		if !equalPoints(res, tc.expected, 1e-9) {
+			t.Errorf("%s: incorrect points. Found(%v), Expected(%v)", tc.name, res, tc.expected)
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	testCases := []struct {
+		name    string
+		encoded string
+	}{
+		{"missing longitude", "_p~iF"},
+		{"unfinished value", "_p~iF~ps|"},
+		{"character below range", "_p~iF~ps|U "},
+		{"character above range", "_p~iF~ps|U\x7f?"},
+		{"value too long", "~~~~~~~~~~~~?"},
+	}
+	for _, tc := range testCases {
+		if _, err := Decode(tc.encoded); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: expected an invalid polyline error. Found(%v)", tc.name, err)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	testCases := []struct {
+		name     string
+		points   []Point
+		expected string
+	}{
+		{"empty", nil, ""},
+		{"zero", []Point{{0, 0}}, "??"},
+		{"google example", []Point{{38.5, -120.2}, {40.7, -120.95}, {43.252, -126.453}}, "_p~iF~ps|U_ulLnnqC_mqNvxq`@"},
+		{"rounded to 5 decimals", []Point{{38.500001, -120.199999}}, "_p~iF~ps|U"},
+	}
+	for _, tc := range testCases {
+		if res := Encode(tc.points); res != tc.expected {
+			t.Errorf("%s: incorrect polyline. Found(%s), Expected(%s)", tc.name, res, tc.expected)
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	points := []Point{{40.748817, -73.985428}, {40.758896, -73.985130}, {-33.856784, 151.215297}, {0.00001, -0.00001}}
+	res, err := Decode(Encode(points))
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if !equalPoints(res, points, 0.5e-5) {
+		t.Errorf("Incorrect points. Found(%v), Expected(%v)", res, points)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	testCases := []struct {
+		name     string
+		a, b     Point
+		expected float64
+	}{
+		{"same point", Point{40, -74}, Point{40, -74}, 0},
+		{"one degree of latitude", Point{40, -74}, Point{41, -74}, 111195},
+		{"one degree of longitude at the equator", Point{0, 0}, Point{0, 1}, 111195},
+		{"one degree of longitude at 60 degrees", Point{60, 0}, Point{60, 1}, 55597},
+	}
+	for _, tc := range testCases {
+		if res := Distance(tc.a, tc.b); math.Abs(res-tc.expected) > 10 {
+			t.Errorf("%s: incorrect distance. Found(%f), Expected(%f)", tc.name, res, tc.expected)
+		}
+	}
+}
+
+func TestLength(t *testing.T) {
+	testCases := []struct {
+		name     string
+		points   []Point
+		expected float64
+	}{
+		{"empty", nil, 0},
+		{"single point", []Point{{40, -74}}, 0},
+		{"out and back", []Point{{40, -74}, {40.01, -74}, {40, -74}}, 2 * 1111.95},
+	}
+	for _, tc := range testCases {
+		if res := Length(tc.points); math.Abs(res-tc.expected) > 0.5 {
+			t.Errorf("%s: incorrect length. Found(%f), Expected(%f)", tc.name, res, tc.expected)
+		}
+	}
+}
+
+func TestBoundingBox(t *testing.T) {
+	testCases := []struct {
+		name     string
+		points   []Point
+		expected Bounds
+		ok       bool
+	}{
+		{"empty", nil, Bounds{}, false},
+		{"single point", []Point{{40, -74}}, Bounds{Point{40, -74}, Point{40, -74}}, true},
+		{"route", []Point{{40.7, -74.0}, {40.8, -73.9}, {40.6, -73.95}}, Bounds{Point{40.6, -74.0}, Point{40.8, -73.9}}, true},
+	}
+	for _, tc := range testCases {
+		res, ok := BoundingBox(tc.points)
+		if ok != tc.ok || res != tc.expected {
+			t.Errorf("%s: incorrect bounds. Found(%v, %t), Expected(%v, %t)", tc.name, res, ok, tc.expected, tc.ok)
+		}
+		for _, p := range tc.points {
+			if !res.Contains(p) {
+				t.Errorf("%s: expected bounds to contain %v", tc.name, p)
+			}
+		}
+	}
+}
+
+func TestSimplify(t *testing.T) {
+	// 0.0001 degrees of latitude is about 11 meters
+	zigzag := []Point{{40, -74}, {40.0001, -73.999}, {40, -73.998}, {40.0001, -73.997}, {40, -73.996}}
+	testCases := []struct {
+		name      string
+		points    []Point
+		tolerance float64
+		expected  []Point
+	}{
+		{"empty", nil, 5, nil},
+		{"two points", []Point{{40, -74}, {40, -73.9}}, 5, []Point{{40, -74}, {40, -73.9}}},
+		{"straight line", []Point{{40, -74}, {40, -73.99}, {40, -73.98}, {40, -73.97}}, 1, []Point{{40, -74}, {40, -73.97}}},
+		{"zigzag below tolerance", zigzag, 20, []Point{{40, -74}, {40, -73.996}}},
+		{"zigzag above tolerance", zigzag, 5, zigzag},
+		{"zero tolerance", zigzag, 0, zigzag},
+		{"corner", []Point{{40, -74}, {40, -73.99}, {40.01, -73.99}}, 5, []Point{{40, -74}, {40, -73.99}, {40.01, -73.99}}},
+		{"loop", []Point{{40, -74}, {40.01, -74}, {40.01, -73.99}, {40, -73.99}, {40, -74}}, 5,
+			[]Point{{40, -74}, {40.01, -74}, {40.01, -73.99}, {40, -73.99}, {40, -74}}},
+	}
+	for _, tc := range testCases {
+		if res := Simplify(tc.points, tc.tolerance); !equalPoints(res, tc.expected, 0) {
+			t.Errorf("%s: incorrect points. Found(%v), Expected(%v)", tc.name, res, tc.expected)
+		}
+	}
+}
+
+func TestTrimZones(t *testing.T) {
+	home := Zone{Center: Point{40, -74}, Radius: 200}
+	// points are about 111 meters apart
+	route := []Point{{40, -74}, {40.001, -74}, {40.002, -74}, {40.003, -74}, {40.002, -74.001}, {40.001, -74}, {40, -74}}
+	testCases := []struct {
+		name     string
+		points   []Point
+		zones    []Zone
+		expected []Point
+	}{
+		{"no zones", route, nil, route},
+		{"start and finish at home", route, []Zone{home}, route[2:5]},
+		{"zone away from the route", route, []Zone{{Point{41, -74}, 500}}, route},
+		{"zone at the turnaround", route, []Zone{{Point{40.003, -74}, 50}}, route},
+		{"every point in a zone", route, []Zone{{Point{40.0015, -74}, 1000}}, nil},
+		{"empty", nil, []Zone{home}, nil},
+	}
+	for _, tc := range testCases {
+		if res := TrimZones(tc.points, tc.zones...); !equalPoints(res, tc.expected, 0) {
+			t.Errorf("%s: incorrect points. Found(%v), Expected(%v)", tc.name, res, tc.expected)
+		}
+	}
+}
+
+func TestTrimEnds(t *testing.T) {
+	// points are about 111 meters apart
+	route := []Point{{40, -74}, {40.001, -74}, {40.002, -74}, {40.003, -74}, {40.004, -74}, {40.005, -74}}
+	testCases := []struct {
+		name     string
+		points   []Point
+		meters   float64
+		expected []Point
+	}{
+		{"no trim", route, 0, route},
+		{"one point", route, 100, route[1:5]},
+		{"two points", route, 200, route[2:4]},
+		{"whole route", route, 300, nil},
+		{"empty", nil, 100, nil},
+	}
+	for _, tc := range testCases {
+		if res := TrimEnds(tc.points, tc.meters); !equalPoints(res, tc.expected, 0) {
+			t.Errorf("%s: incorrect points. Found(%v), Expected(%v)", tc.name, res, tc.expected)
+		}
+	}
+}
+
+func TestPrivacyApply(t *testing.T) {
+	// points are about 111 meters apart
+	route := []Point{{40, -74}, {40.001, -74}, {40.002, -74}, {40.003, -74}, {40.004, -74}, {40.005, -74}, {40.006, -74}}
+	home := Zone{Center: Point{40, -74}, Radius: 150}
+	testCases := []struct {
+		name     string
+		privacy  Privacy
+		expected []Point
+		offset   float64
+	}{
+		{"nothing hidden", Privacy{}, route, 0},
+		{"zone", Privacy{Zones: []Zone{home}}, route[2:], 2 * 111.195},
+		{"hidden ends", Privacy{HideEnds: 100}, route[1:6], 111.195},
+		{"zone and hidden ends", Privacy{Zones: []Zone{home}, HideEnds: 100}, route[3:6], 3 * 111.195},
+		{"everything hidden", Privacy{HideEnds: 1000}, nil, 0},
+	}
+	for _, tc := range testCases {
+		res, offset := tc.privacy.Apply(route)
+		if !equalPoints(res, tc.expected, 0) {
+			t.Errorf("%s: incorrect points. Found(%v), Expected(%v)", tc.name, res, tc.expected)
+		}
+		if math.Abs(offset-tc.offset) > 0.5 {
+			t.Errorf("%s: incorrect offset. Found(%f), Expected(%f)", tc.name, offset, tc.offset)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Package records computes personal records from saved races
+package records
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/ddominguez/run-david-run/db"
+)
+
+// Record is the best time of a sport at a standard race distance
+type Record struct {
+	SportType string
+	Category  db.RaceCategory
+	Best      db.RaceActivity
+	// Progression are the races that improved the record, oldest first
+	Progression []db.RaceActivity
+}
+
+// Records are the personal records of every sport and race distance
+type Records struct {
+	Records []Record
+	// prs are the ids of the races that set a record when they were run
+	prs map[uint64]bool
+}
+
+// SetPR returns true when the race improved its record when it was run
+func (r Records) SetPR(stravaId uint64) bool {
+	return r.prs[stravaId]
+}
+
+// PRs returns the ids of the races that set a record
+func (r Records) PRs() map[uint64]bool {
+	return r.prs
+}
+
+// comparable returns the category of a race that has a record, ultras
+// are left out because their distances are not the same, and hidden races
+// because they are not on the site.
+func comparable(a db.RaceActivity) (db.RaceCategory, bool) {
+	c, ok := a.Category()
+	if !ok || c.Key == db.UltraCategory || a.ElapsedTime == 0 || a.IsHidden() {
+		return c, false
+	}
+	return c, true
+}
+
+// Compute returns the records of the races. Races are compared by
+// elapsed time, the official race time.
+func Compute(activities []db.RaceActivity) Records {
+	races := slices.Clone(activities)
+	sort.SliceStable(races, func(i, j int) bool {
+		return races[i].StartDate < races[j].StartDate
+	})
+
+	type key struct{ sport, category string }
+	byKey := map[key]*Record{}
+	res := Records{prs: map[uint64]bool{}}
+	for _, a := range races {
+		c, ok := comparable(a)
+		if !ok {
+			continue
+		}
+		k := key{a.SportType, c.Key}
+		rec, found := byKey[k]
+		if !found {
+			rec = &Record{SportType: a.SportType, Category: c}
+			byKey[k] = rec
+		} else if a.ElapsedTime >= rec.Best.ElapsedTime {
+			continue
+		}
+		rec.Best = a
+		rec.Progression = append(rec.Progression, a)
+		res.prs[a.StravaId] = true
+	}
+
+	for _, rec := range byKey {
+		res.Records = append(res.Records, *rec)
+	}
+	categoryIndex := func(c db.RaceCategory) int {
+		return slices.IndexFunc(db.RaceCategories, func(rc db.RaceCategory) bool {
+			return rc.Key == c.Key
+		})
+	}
+	sort.Slice(res.Records, func(i, j int) bool {
+		a, b := res.Records[i], res.Records[j]
+		if a.SportType != b.SportType {
+			return a.SportType < b.SportType
+		}
+		return categoryIndex(a.Category) < categoryIndex(b.Category)
+	})
+	return res
+}
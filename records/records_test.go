@@ -0,0 +1,112 @@
+package records
+
+import (
+	"testing"
+
+	"github.com/ddominguez/run-david-run/db"
+)
+
+func newRace(id uint64, sportType string, distance float64, elapsed uint32, start string) db.RaceActivity {
+	return db.RaceActivity{
+		StravaId:    id,
+		Name:        "Race",
+		SportType:   sportType,
+		Distance:    distance,
+		ElapsedTime: elapsed,
+		StartDate:   db.DateTime(start),
+	}
+}
+
+func TestCompute(t *testing.T) {
+	hidden := newRace(7, "Run", 5000, 1000, "2023-06-01T08:00:00Z")
+	hidden.HiddenAt = "2023-07-01T00:00:00Z"
+	// newest first, like the races of the database
+	activities := []db.RaceActivity{
+		newRace(6, "Run", 10000, 2500, "2023-09-01T08:00:00Z"),
+		hidden,
+		// a tie does not improve the record
+		newRace(5, "Run", 5000, 1400, "2023-05-01T08:00:00Z"),
+		newRace(4, "Run", 5000, 1400, "2023-04-01T08:00:00Z"),
+		newRace(3, "Run", 5000, 1500, "2023-03-01T08:00:00Z"),
+		newRace(2, "Run", 5000, 1450, "2023-02-01T08:00:00Z"),
+		newRace(1, "Run", 5010, 1480, "2023-01-01T08:00:00Z"),
+		// ultras and races without a category have no records
+		newRace(8, "Run", 60000, 30000, "2023-10-01T08:00:00Z"),
+		newRace(9, "Run", 12000, 3000, "2023-10-02T08:00:00Z"),
+	}
+
+	res := Compute(activities)
+	tests := []struct {
+		category    string
+		best        uint64
+		progression []uint64
+	}{
+		{"5k", 4, []uint64{1, 2, 4}},
+		{"10k", 6, []uint64{6}},
+	}
+	if len(res.Records) != len(tests) {
+		t.Fatalf("Incorrect number of records. Found(%d), Expected(%d)", len(res.Records), len(tests))
+	}
+	for i, test := range tests {
+		rec := res.Records[i]
+		if rec.Category.Key != test.category || rec.Best.StravaId != test.best {
+			t.Errorf("Incorrect record %d. Found(%s %d), Expected(%s %d)", i, rec.Category.Key, rec.Best.StravaId, test.category, test.best)
+		}
+		var ids []uint64
+		for _, a := range rec.Progression {
+			ids = append(ids, a.StravaId)
+		}
+		if len(ids) != len(test.progression) {
+			t.Errorf("Incorrect progression of %s. Found(%v), Expected(%v)", test.category, ids, test.progression)
+			continue
+		}
+		for j := range ids {
+			if ids[j] != test.progression[j] {
+				t.Errorf("Incorrect progression of %s. Found(%v), Expected(%v)", test.category, ids, test.progression)
+				break
+			}
+		}
+	}
+
+	prs := []struct {
+		id    uint64
+		setPR bool
+	}{
+		{1, true},
+		{2, true},
+		{3, false},
+		{4, true},
+		{5, false},
+		{6, true},
+		{7, false},
+		{8, false},
+	}
+	for _, test := range prs {
+		if res.SetPR(test.id) != test.setPR {
+			t.Errorf("Incorrect PR of race %d. Found(%v), Expected(%v)", test.id, res.SetPR(test.id), test.setPR)
+		}
+	}
+}
+
+func TestComputeSports(t *testing.T) {
+	ride := newRace(2, "Ride", 10000, 900, "2023-02-01T08:00:00Z")
+	ride.CategoryOverride = "10k"
+	activities := []db.RaceActivity{
+		newRace(1, "Run", 10000, 2500, "2023-01-01T08:00:00Z"),
+		ride,
+		newRace(3, "TrailRun", 10000, 3000, "2023-03-01T08:00:00Z"),
+		// rides are not classified by distance
+		newRace(4, "Ride", 5000, 500, "2023-04-01T08:00:00Z"),
+	}
+
+	res := Compute(activities)
+	expected := []string{"Ride", "Run", "TrailRun"}
+	if len(res.Records) != len(expected) {
+		t.Fatalf("Incorrect number of records. Found(%d), Expected(%d)", len(res.Records), len(expected))
+	}
+	for i, sport := range expected {
+		if res.Records[i].SportType != sport {
+			t.Errorf("Incorrect sport of record %d. Found(%s), Expected(%s)", i, res.Records[i].SportType, sport)
+		}
+	}
+}
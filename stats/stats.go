@@ -0,0 +1,106 @@
+// Package stats computes yearly summaries of saved races
+package stats
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ddominguez/run-david-run/db"
+	"github.com/ddominguez/run-david-run/utils"
+)
+
+// CategoryCount is the number of races of a race category
+type CategoryCount struct {
+	// Category is empty for races that are not a standard distance
+	Category db.RaceCategory
+	Count    int
+}
+
+// Month are the races of a month
+type Month struct {
+	Month time.Month
+	Races []db.RaceActivity
+}
+
+// Year is the summary of the races of a year
+type Year struct {
+	Year        int
+	Races       []db.RaceActivity
+	Distance    float64
+	ElapsedTime uint32
+	// Fastest is the race with the fastest pace, of sports measured by pace
+	Fastest    db.RaceActivity
+	HasFastest bool
+	// Mix is the number of races by category, from the shortest to the
+	// longest distance and races without a category last
+	Mix    []CategoryCount
+	Months [12]Month
+}
+
+// pace returns the seconds per meter of a race
+func pace(a db.RaceActivity) float64 {
+	return float64(a.ElapsedTime) / a.Distance
+}
+
+func (y *Year) add(a db.RaceActivity, month time.Month) {
+	y.Races = append(y.Races, a)
+	y.Distance += a.Distance
+	y.ElapsedTime += a.ElapsedTime
+	y.Months[month-1].Races = append(y.Months[month-1].Races, a)
+
+	if utils.UsesPace(a.SportType) && a.Distance > 0 && a.ElapsedTime > 0 {
+		if !y.HasFastest || pace(a) < pace(y.Fastest) {
+			y.Fastest, y.HasFastest = a, true
+		}
+	}
+}
+
+// mix counts the races by category
+func mix(races []db.RaceActivity) []CategoryCount {
+	counts := map[string]int{}
+	for _, a := range races {
+		c, _ := a.Category()
+		counts[c.Key]++
+	}
+	var res []CategoryCount
+	for _, c := range db.RaceCategories {
+		if n := counts[c.Key]; n > 0 {
+			res = append(res, CategoryCount{c, n})
+		}
+	}
+	if n := counts[""]; n > 0 {
+		res = append(res, CategoryCount{Count: n})
+	}
+	return res
+}
+
+// ByYear returns the summary of every year with races, newest first.
+// Races are in the order of activities within a year and a month.
+func ByYear(activities []db.RaceActivity) ([]Year, error) {
+	byYear := map[int]*Year{}
+	for _, a := range activities {
+		t, err := time.Parse(time.RFC3339, string(a.StartDate))
+		if err != nil {
+			return nil, err
+		}
+		y, ok := byYear[t.Year()]
+		if !ok {
+			y = &Year{Year: t.Year()}
+			for m := range y.Months {
+				y.Months[m].Month = time.Month(m + 1)
+			}
+			byYear[t.Year()] = y
+		}
+		y.add(a, t.Month())
+	}
+
+	res := make([]Year, 0, len(byYear))
+	for _, y := range byYear {
+		y.Mix = mix(y.Races)
+		res = append(res, *y)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Year > res[j].Year
+	})
+	return res, nil
+}
@@ -0,0 +1,91 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ddominguez/run-david-run/db"
+)
+
+func newRace(id uint64, sportType string, distance float64, elapsed uint32, start string) db.RaceActivity {
+	return db.RaceActivity{
+		StravaId:    id,
+		SportType:   sportType,
+		Distance:    distance,
+		ElapsedTime: elapsed,
+		StartDate:   db.DateTime(start),
+	}
+}
+
+func TestByYear(t *testing.T) {
+	// newest first, like the races of the database
+	activities := []db.RaceActivity{
+		newRace(5, "Run", 5000, 1300, "2024-01-01T00:10:00Z"),
+		// the last race of the year
+		newRace(4, "Run", 42195, 13000, "2023-12-31T23:30:00Z"),
+		// rides are not the fastest pace and have no category
+		newRace(3, "Ride", 40000, 3600, "2023-06-10T07:00:00Z"),
+		newRace(2, "Run", 5010, 1200, "2023-06-03T08:00:00Z"),
+		newRace(1, "Run", 12000, 3000, "2023-01-15T09:00:00Z"),
+	}
+
+	years, err := ByYear(activities)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if len(years) != 2 || years[0].Year != 2024 || years[1].Year != 2023 {
+		t.Fatalf("Incorrect years. Found(%d years), Expected(2024, 2023)", len(years))
+	}
+
+	y := years[1]
+	if len(y.Races) != 4 || y.Distance != 99205 || y.ElapsedTime != 20800 {
+		t.Errorf("Incorrect totals of 2023. Found(%d races, %.0f m, %d s), Expected(4 races, 99205 m, 20800 s)",
+			len(y.Races), y.Distance, y.ElapsedTime)
+	}
+	if !y.HasFastest || y.Fastest.StravaId != 2 {
+		t.Errorf("Incorrect fastest race. Found(%d), Expected(%d)", y.Fastest.StravaId, 2)
+	}
+
+	mix := []struct {
+		category string
+		count    int
+	}{
+		{"5k", 1},
+		{"marathon", 1},
+		{"", 2},
+	}
+	if len(y.Mix) != len(mix) {
+		t.Fatalf("Incorrect mix. Found(%+v)", y.Mix)
+	}
+	for i, m := range mix {
+		if y.Mix[i].Category.Key != m.category || y.Mix[i].Count != m.count {
+			t.Errorf("Incorrect mix %d. Found(%s %d), Expected(%s %d)", i, y.Mix[i].Category.Key, y.Mix[i].Count, m.category, m.count)
+		}
+	}
+
+	months := []struct {
+		month time.Month
+		races int
+	}{
+		{time.January, 1},
+		{time.February, 0},
+		{time.June, 2},
+		{time.December, 1},
+	}
+	for _, m := range months {
+		found := y.Months[m.month-1]
+		if found.Month != m.month || len(found.Races) != m.races {
+			t.Errorf("Incorrect races of %s. Found(%s %d), Expected(%d)", m.month, found.Month, len(found.Races), m.races)
+		}
+	}
+	// races keep the order of activities within a month
+	if june := y.Months[time.June-1].Races; june[0].StravaId != 3 || june[1].StravaId != 2 {
+		t.Errorf("Incorrect order of the races of June. Found(%d, %d), Expected(3, 2)", june[0].StravaId, june[1].StravaId)
+	}
+}
+
+func TestByYearInvalidDate(t *testing.T) {
+	if _, err := ByYear([]db.RaceActivity{newRace(1, "Run", 5000, 1200, "June 3")}); err == nil {
+		t.Errorf("Expected an error for an invalid start date")
+	}
+}
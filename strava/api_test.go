@@ -0,0 +1,215 @@
+package strava_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/ddominguez/run-david-run/strava"
+	"github.com/ddominguez/run-david-run/strava/stravatest"
+)
+
+func newActivity(id uint64, name, start string) strava.Activity {
+	a := strava.Activity{
+		Id:             id,
+		Name:           name,
+		Distance:       5000,
+		MovingTime:     1200,
+		ElapsedTime:    1230,
+		SportType:      "Run",
+		WorkoutType:    1,
+		StartDateLocal: start,
+	}
+	a.Map.SummaryPolyline = "_p~iF~ps|U_ulLnnqC_mqNvxq`@"
+	return a
+}
+
+func newAuthorization(srv *stravatest.Server) *strava.Authorization {
+	a := &strava.Authorization{
+		ClientId:     "testid",
+		ClientSecret: "testsecret",
+		RedirectUri:  "http://localhost/callback",
+		Scope:        "activity:read_all",
+	}
+	a.Configure(srv.Options()...)
+	return a
+}
+
+func TestGetActivitiesPaging(t *testing.T) {
+	srv := stravatest.NewServer()
+	defer srv.Close()
+	srv.AddActivities(
+		newActivity(1, "First 5K", "2023-01-01T09:00:00Z"),
+		newActivity(2, "Second 5K", "2023-02-01T09:00:00Z"),
+		newActivity(3, "Third 5K", "2023-03-01T09:00:00Z"),
+	)
+
+	c := strava.NewClient(srv.Token().AccessToken, srv.Options()...)
+	var ids []uint64
+	for page := uint16(1); page < 10; page++ {
+		activities, err := strava.GetActivities(c, strava.ReqParams{Page: page, PerPage: 2})
+		if err != nil {
+			t.Fatalf("Unexpected error for page %d. %s", page, err)
+		}
+		if len(activities) == 0 {
+			break
+		}
+		for _, a := range activities {
+			ids = append(ids, a.Id)
+		}
+	}
+
+	expected := []uint64{1, 2, 3}
+	if len(ids) != len(expected) {
+		t.Fatalf("Incorrect activities. Found(%v), Expected(%v)", ids, expected)
+	}
+	for i := range expected {
+		if ids[i] != expected[i] {
+			t.Errorf("Incorrect activity order. Found(%v), Expected(%v)", ids, expected)
+			break
+		}
+	}
+	if n := srv.Requests("GET /api/v3/athlete/activities"); n != 3 {
+		t.Errorf("Incorrect number of requests. Found(%d), Expected(%d)", n, 3)
+	}
+}
+
+func TestGetActivity(t *testing.T) {
+	srv := stravatest.NewServer()
+	defer srv.Close()
+	srv.AddActivities(newActivity(42, "NYC Marathon", "2023-11-05T09:10:00Z"))
+
+	c := strava.NewClient(srv.Token().AccessToken, srv.Options()...)
+	a, err := strava.GetActivity(c, 42)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if a.Name != "NYC Marathon" {
+		t.Errorf("Incorrect activity name. Found(%s), Expected(%s)", a.Name, "NYC Marathon")
+	}
+
+	if _, err := strava.GetActivity(c, 43); !strava.IsNotFound(err) {
+		t.Errorf("Expected a not found error for a missing activity. Found(%v)", err)
+	}
+}
+
+func TestReqAccessToken(t *testing.T) {
+	srv := stravatest.NewServer()
+	defer srv.Close()
+	srv.SetAthlete(strava.Athlete{Id: 7, FirstName: "David"})
+
+	resp, err := newAuthorization(srv).ReqAccessToken(srv.Code())
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if resp.AccessToken != srv.Token().AccessToken {
+		t.Errorf("Incorrect access token. Found(%s), Expected(%s)", resp.AccessToken, srv.Token().AccessToken)
+	}
+	if resp.Athlete.Id != 7 {
+		t.Errorf("Incorrect athlete id. Found(%d), Expected(%d)", resp.Athlete.Id, 7)
+	}
+
+	if _, err := newAuthorization(srv).ReqAccessToken("bad-code"); !strava.IsUnauthorized(err) {
+		t.Errorf("Expected an unauthorized error for an invalid code. Found(%v)", err)
+	}
+}
+
+func TestTokenClientRefreshesExpiredToken(t *testing.T) {
+	srv := stravatest.NewServer()
+	defer srv.Close()
+	srv.AddActivities(newActivity(1, "First 5K", "2023-01-01T09:00:00Z"))
+	srv.ExpireToken()
+
+	var saved strava.Token
+	ts := strava.NewTokenSource(newAuthorization(srv), srv.Token(), func(tk strava.Token) error {
+		saved = tk
+		return nil
+	})
+	c := strava.NewTokenClient(ts, srv.Options()...)
+
+	for i := 0; i < 2; i++ {
+		if _, err := strava.GetActivities(c, strava.ReqParams{Page: 1, PerPage: 10}); err != nil {
+			t.Fatalf("Unexpected error. %s", err)
+		}
+	}
+	if srv.Refreshes() != 1 {
+		t.Errorf("Incorrect number of refreshes. Found(%d), Expected(%d)", srv.Refreshes(), 1)
+	}
+	if saved != srv.Token() {
+		t.Errorf("Refreshed token was not saved. Found(%+v), Expected(%+v)", saved, srv.Token())
+	}
+}
+
+func TestRevokedAccessToken(t *testing.T) {
+	srv := stravatest.NewServer()
+	defer srv.Close()
+
+	c := strava.NewClient("revoked", srv.Options()...)
+	_, err := strava.GetActivities(c, strava.ReqParams{Page: 1, PerPage: 10})
+	var apiErr *strava.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected an APIError. Found(%v)", err)
+	}
+	if !strava.IsUnauthorized(err) || apiErr.Message != "Authorization Error" {
+		t.Errorf("Unexpected APIError. Found(%+v)", apiErr)
+	}
+	if apiErr.RateLimit.ShortTermLimit == 0 {
+		t.Errorf("Expected the APIError to include the rate limit")
+	}
+}
+
+type countingTransport struct {
+	count int
+	next  http.RoundTripper
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.count++
+	return c.next.RoundTrip(req)
+}
+
+func TestWithTransport(t *testing.T) {
+	srv := stravatest.NewServer()
+	defer srv.Close()
+
+	rt := &countingTransport{next: srv.Client().Transport}
+	c := strava.NewClient(srv.Token().AccessToken, strava.WithAPIURL(srv.APIURL()), strava.WithTransport(rt))
+	if _, err := strava.GetActivities(c, strava.ReqParams{Page: 1, PerPage: 10}); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if rt.count != 1 {
+		t.Errorf("Custom transport was not used. Found(%d), Expected(%d)", rt.count, 1)
+	}
+}
+
+func TestGetActivityStreams(t *testing.T) {
+	srv := stravatest.NewServer()
+	defer srv.Close()
+	srv.AddActivities(newActivity(42, "NYC Marathon", "2023-11-05T09:10:00Z"))
+	srv.SetStreams(42, strava.Streams{
+		LatLng:    [][2]float64{{40.6, -74.05}, {40.61, -74.04}},
+		Time:      []int{0, 10},
+		Distance:  []float64{0, 31.4},
+		Heartrate: []int{120, 131},
+	})
+
+	c := strava.NewClient(srv.Token().AccessToken, srv.Options()...)
+	s, err := strava.GetActivityStreams(c, 42)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if s.Len() != 2 || s.LatLng[1] != [2]float64{40.61, -74.04} || s.Heartrate[1] != 131 {
+		t.Errorf("Streams were not decoded. Found(%+v)", s)
+	}
+	if len(s.Altitude) != 0 || len(s.Cadence) != 0 {
+		t.Errorf("Expected streams that were not recorded to be empty. Found(%+v)", s)
+	}
+
+	s, err = strava.GetActivityStreams(c, 42, strava.StreamTime)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if len(s.Time) != 2 || len(s.LatLng) != 0 {
+		t.Errorf("Expected only the time stream. Found(%+v)", s)
+	}
+}
@@ -0,0 +1,143 @@
+package strava
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUnauthorized matches errors caused by an invalid, expired or revoked
+	// access token, refresh token or authorization code.
+	ErrUnauthorized = errors.New("strava unauthorized")
+	// ErrNotFound matches errors for resources that don't exist, e.g. a deleted activity.
+	ErrNotFound = errors.New("strava resource not found")
+	// ErrRateLimited matches errors for requests rejected by the rate limit
+	ErrRateLimited = errors.New("strava rate limit exceeded")
+)
+
+// maxErrorBody is the most bytes read from an error response body
+const maxErrorBody = 64 << 10
+
+// Fault is a single entry of the errors list in a strava fault response
+type Fault struct {
+	Resource string `json:"resource"`
+	Field    string `json:"field"`
+	Code     string `json:"code"`
+}
+
+func (f Fault) String() string {
+	return strings.TrimSpace(fmt.Sprintf("%s %s %s", f.Resource, f.Field, f.Code))
+}
+
+// APIError is returned for unsuccessful strava responses.
+// Use errors.Is with ErrUnauthorized, ErrNotFound, ErrRateLimited or
+// ErrDailyRateLimit to check for a kind of error.
+type APIError struct {
+	StatusCode int
+	Status     string
+	Message    string
+	Errors     []Fault
+	Path       string
+	RateLimit  RateLimit
+}
+
+// newAPIError reads the fault payload from the response body and closes it
+func newAPIError(resp *http.Response, rl RateLimit) *APIError {
+	defer resp.Body.Close()
+
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		RateLimit:  rl,
+	}
+	if resp.Request != nil {
+		e.Path = resp.Request.URL.Path
+	}
+
+	var fault struct {
+		Message string  `json:"message"`
+		Errors  []Fault `json:"errors"`
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if err := json.Unmarshal(body, &fault); err == nil {
+		e.Message = fault.Message
+		e.Errors = fault.Errors
+	} else {
+		e.Message = strings.TrimSpace(string(body))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return e
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "strava api error %s", e.Status)
+	if e.Path != "" {
+		fmt.Fprintf(&b, " for %s", e.Path)
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&b, ": %s", e.Message)
+	}
+	if len(e.Errors) > 0 {
+		faults := make([]string, len(e.Errors))
+		for i, f := range e.Errors {
+			faults[i] = f.String()
+		}
+		fmt.Fprintf(&b, " (%s)", strings.Join(faults, "; "))
+	}
+	if e.Is(ErrDailyRateLimit) {
+		fmt.Fprintf(&b, ", daily usage %d/%d resets at %s",
+			e.RateLimit.DailyUsage, e.RateLimit.DailyLimit, e.RateLimit.DailyReset().Format(time.RFC3339))
+	}
+	return b.String()
+}
+
+// Is reports whether the error matches one of the strava error kinds
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.invalidCredentials()
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrDailyRateLimit:
+		return e.StatusCode == http.StatusTooManyRequests && e.RateLimit.DailyExceeded()
+	}
+	return false
+}
+
+// invalidCredentials returns true for bad requests caused by an invalid token or code.
+// Strava returns these when a refresh token was revoked or an authorization code was reused.
+func (e *APIError) invalidCredentials() bool {
+	if e.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	for _, f := range e.Errors {
+		switch f.Field {
+		case "access_token", "refresh_token", "code":
+			return true
+		}
+	}
+	return false
+}
+
+// IsUnauthorized returns true when err is caused by invalid or revoked credentials
+func IsUnauthorized(err error) bool {
+	return errors.Is(err, ErrUnauthorized)
+}
+
+// IsNotFound returns true when err is caused by a missing strava resource
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// IsRateLimited returns true when err is caused by the strava rate limit
+func IsRateLimited(err error) bool {
+	return errors.Is(err, ErrRateLimited)
+}
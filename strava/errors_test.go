@@ -0,0 +1,93 @@
+package strava
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type trackingBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *trackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func newErrorResponse(status int, body string) (*http.Response, *trackingBody) {
+	b := &trackingBody{Reader: strings.NewReader(body)}
+	return &http.Response{
+		StatusCode: status,
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Body:       b,
+		Request:    &http.Request{URL: &url.URL{Path: "/api/v3/activities/1"}},
+	}, b
+}
+
+func TestNewAPIError(t *testing.T) {
+	resp, body := newErrorResponse(http.StatusNotFound,
+		`{"message":"Record Not Found","errors":[{"resource":"Activity","field":"id","code":"not found"}]}`)
+	e := newAPIError(resp, RateLimit{})
+
+	if !body.closed {
+		t.Errorf("Expected the response body to be closed")
+	}
+	if e.Message != "Record Not Found" || len(e.Errors) != 1 || e.Errors[0].Resource != "Activity" {
+		t.Errorf("Fault payload was not parsed. Found(%+v)", e)
+	}
+	if e.Path != "/api/v3/activities/1" {
+		t.Errorf("Incorrect path. Found(%s), Expected(%s)", e.Path, "/api/v3/activities/1")
+	}
+
+	resp, _ = newErrorResponse(http.StatusBadGateway, "<html>bad gateway</html>")
+	e = newAPIError(resp, RateLimit{})
+	if e.Message != "<html>bad gateway</html>" {
+		t.Errorf("Expected the raw body as message. Found(%s)", e.Message)
+	}
+}
+
+func TestAPIErrorIs(t *testing.T) {
+	dailyExceeded := RateLimit{200, 10, 2000, 2000, time.Now()}
+	testCases := []struct {
+		err          *APIError
+		unauthorized bool
+		notFound     bool
+		rateLimited  bool
+		daily        bool
+	}{
+		{&APIError{StatusCode: http.StatusUnauthorized}, true, false, false, false},
+		{&APIError{StatusCode: http.StatusBadRequest, Errors: []Fault{{"RefreshToken", "refresh_token", "invalid"}}}, true, false, false, false},
+		{&APIError{StatusCode: http.StatusBadRequest, Errors: []Fault{{"Activities", "page", "invalid"}}}, false, false, false, false},
+		{&APIError{StatusCode: http.StatusNotFound}, false, true, false, false},
+		{&APIError{StatusCode: http.StatusTooManyRequests}, false, false, true, false},
+		{&APIError{StatusCode: http.StatusTooManyRequests, RateLimit: dailyExceeded}, false, false, true, true},
+		{&APIError{StatusCode: http.StatusServiceUnavailable}, false, false, false, false},
+	}
+
+	for _, tc := range testCases {
+		err := fmt.Errorf("wrapped: %w", tc.err)
+		if IsUnauthorized(err) != tc.unauthorized {
+			t.Errorf("IsUnauthorized(%d) has unexpected value. Expected(%t)", tc.err.StatusCode, tc.unauthorized)
+		}
+		if IsNotFound(err) != tc.notFound {
+			t.Errorf("IsNotFound(%d) has unexpected value. Expected(%t)", tc.err.StatusCode, tc.notFound)
+		}
+		if IsRateLimited(err) != tc.rateLimited {
+			t.Errorf("IsRateLimited(%d) has unexpected value. Expected(%t)", tc.err.StatusCode, tc.rateLimited)
+		}
+		if errors.Is(err, ErrDailyRateLimit) != tc.daily {
+			t.Errorf("errors.Is(%d, ErrDailyRateLimit) has unexpected value. Expected(%t)", tc.err.StatusCode, tc.daily)
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != tc.err.StatusCode {
+			t.Errorf("errors.As did not find the APIError for %d", tc.err.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package strava
+
+import (
+	"slices"
+	"strings"
+)
+
+// Strava workout types that mark an activity as a race
+const (
+	WorkoutTypeRunRace  uint8 = 1
+	WorkoutTypeRideRace uint8 = 11
+)
+
+// RacePolicy decides which activities are races.
+//
+// Activities listed in ExcludeIds are never races and activities listed in
+// IncludeIds always are. Other activities must have one of the SportTypes and
+// either one of the WorkoutTypes or a name containing one of the NameKeywords.
+// An empty SportTypes list allows every sport type.
+type RacePolicy struct {
+	SportTypes   []string
+	WorkoutTypes []uint8
+	NameKeywords []string
+	IncludeIds   []uint64
+	ExcludeIds   []uint64
+}
+
+// DefaultRacePolicy returns the policy that selects running races
+func DefaultRacePolicy() RacePolicy {
+	return RacePolicy{
+		SportTypes:   []string{"Run"},
+		WorkoutTypes: []uint8{WorkoutTypeRunRace},
+	}
+}
+
+// Match returns true when the policy considers the activity a race
+func (p RacePolicy) Match(a Activity) bool {
+	if slices.Contains(p.ExcludeIds, a.Id) {
+		return false
+	}
+	if slices.Contains(p.IncludeIds, a.Id) {
+		return true
+	}
+	if len(p.SportTypes) > 0 && !slices.Contains(p.SportTypes, a.SportType) {
+		return false
+	}
+	if slices.Contains(p.WorkoutTypes, a.WorkoutType) {
+		return true
+	}
+
+	name := strings.ToLower(a.Name)
+	for _, k := range p.NameKeywords {
+		if k != "" && strings.Contains(name, strings.ToLower(k)) {
+			return true
+		}
+	}
+	return false
+}
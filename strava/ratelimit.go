@@ -0,0 +1,127 @@
+package strava
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrDailyRateLimit is returned once the daily request quota has been used up.
+// Requests will not succeed again until the quota resets at midnight UTC.
+var ErrDailyRateLimit = errors.New("strava daily rate limit exceeded")
+
+// RateLimit is a snapshot of the api usage reported by strava.
+// Strava limits requests per 15 minute window and per day.
+type RateLimit struct {
+	ShortTermLimit int
+	ShortTermUsage int
+	DailyLimit     int
+	DailyUsage     int
+	UpdatedAt      time.Time
+}
+
+// ShortTermExceeded returns true when the 15 minute quota is used up
+func (r RateLimit) ShortTermExceeded() bool {
+	return r.ShortTermLimit > 0 && r.ShortTermUsage >= r.ShortTermLimit
+}
+
+// DailyExceeded returns true when the daily quota is used up
+func (r RateLimit) DailyExceeded() bool {
+	return r.DailyLimit > 0 && r.DailyUsage >= r.DailyLimit
+}
+
+// ShortTermReset returns when the 15 minute window of the snapshot ends.
+// Strava windows start at 0, 15, 30 and 45 minutes after the hour.
+func (r RateLimit) ShortTermReset() time.Time {
+	return r.UpdatedAt.UTC().Truncate(15 * time.Minute).Add(15 * time.Minute)
+}
+
+// DailyReset returns when the daily window of the snapshot ends
+func (r RateLimit) DailyReset() time.Time {
+	t := r.UpdatedAt.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+}
+
+func (r RateLimit) String() string {
+	return fmt.Sprintf("%d/%d (15 min), %d/%d (daily)", r.ShortTermUsage, r.ShortTermLimit, r.DailyUsage, r.DailyLimit)
+}
+
+// parseLimitPair parses header values formatted as "short,daily"
+func parseLimitPair(v string) (int, int, bool) {
+	parts := strings.Split(v, ",")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	short, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, false
+	}
+	daily, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return 0, 0, false
+	}
+	return short, daily, true
+}
+
+// parseRateLimit reads the rate limit headers of a strava response.
+// The read limits are preferred when present because every api request
+// made by the client is a read request.
+func parseRateLimit(h http.Header, now time.Time) (RateLimit, bool) {
+	limitKey, usageKey := "X-RateLimit-Limit", "X-RateLimit-Usage"
+	if h.Get("X-ReadRateLimit-Limit") != "" {
+		limitKey, usageKey = "X-ReadRateLimit-Limit", "X-ReadRateLimit-Usage"
+	}
+
+	shortLimit, dailyLimit, ok := parseLimitPair(h.Get(limitKey))
+	if !ok {
+		return RateLimit{}, false
+	}
+	shortUsage, dailyUsage, ok := parseLimitPair(h.Get(usageKey))
+	if !ok {
+		return RateLimit{}, false
+	}
+	return RateLimit{
+		ShortTermLimit: shortLimit,
+		ShortTermUsage: shortUsage,
+		DailyLimit:     dailyLimit,
+		DailyUsage:     dailyUsage,
+		UpdatedAt:      now,
+	}, true
+}
+
+const (
+	retryBaseDelay = time.Second
+	retryMaxDelay  = time.Minute
+	retryMaxJitter = 15 * time.Second
+)
+
+// retryDelay returns how long to wait before retrying a failed request.
+// When the 15 minute quota is used up the client waits for the next window,
+// otherwise it backs off exponentially. Both add jitter so that several
+// clients don't retry at the same moment.
+func retryDelay(attempt int, resp *http.Response, rl RateLimit, now time.Time) time.Duration {
+	jitter := func(d time.Duration) time.Duration {
+		return d + time.Duration(rand.Int64N(int64(min(d/2, retryMaxJitter))+1))
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests && rl.ShortTermExceeded() {
+		return jitter(rl.ShortTermReset().Sub(now) + time.Second)
+	}
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s > 0 {
+		return jitter(time.Duration(s) * time.Second)
+	}
+
+	d := retryBaseDelay << attempt
+	if d <= 0 || d > retryMaxDelay {
+		d = retryMaxDelay
+	}
+	return jitter(d)
+}
+
+func isRetryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
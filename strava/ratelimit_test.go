@@ -0,0 +1,156 @@
+package strava
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 20, 0, 0, time.UTC)
+	testCases := []struct {
+		headers  map[string]string
+		ok       bool
+		expected RateLimit
+	}{
+		{
+			map[string]string{"X-RateLimit-Limit": "200,2000", "X-RateLimit-Usage": "12,340"},
+			true,
+			RateLimit{200, 12, 2000, 340, now},
+		},
+		{
+			map[string]string{
+				"X-RateLimit-Limit": "200,2000", "X-RateLimit-Usage": "12,340",
+				"X-ReadRateLimit-Limit": "100,1000", "X-ReadRateLimit-Usage": "10,300",
+			},
+			true,
+			RateLimit{100, 10, 1000, 300, now},
+		},
+		{map[string]string{"X-RateLimit-Limit": "200", "X-RateLimit-Usage": "12,340"}, false, RateLimit{}},
+		{map[string]string{}, false, RateLimit{}},
+	}
+
+	for _, tc := range testCases {
+		h := http.Header{}
+		for k, v := range tc.headers {
+			h.Set(k, v)
+		}
+		rl, ok := parseRateLimit(h, now)
+		if ok != tc.ok || rl != tc.expected {
+			t.Errorf("parseRateLimit(%v) has unexpected value. Found(%+v, %t), Expected(%+v, %t)", tc.headers, rl, ok, tc.expected, tc.ok)
+		}
+	}
+}
+
+func TestRateLimitResets(t *testing.T) {
+	rl := RateLimit{UpdatedAt: time.Date(2024, 5, 1, 10, 20, 0, 0, time.UTC)}
+	if expected := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC); !rl.ShortTermReset().Equal(expected) {
+		t.Errorf("Incorrect short term reset. Found(%s), Expected(%s)", rl.ShortTermReset(), expected)
+	}
+	if expected := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC); !rl.DailyReset().Equal(expected) {
+		t.Errorf("Incorrect daily reset. Found(%s), Expected(%s)", rl.DailyReset(), expected)
+	}
+}
+
+func newTestClient(url string) (*Client, *[]time.Duration) {
+	var sleeps []time.Duration
+	c := NewClient("token", WithAPIURL(url))
+	c.sleep = func(d time.Duration) {
+		sleeps = append(sleeps, d)
+	}
+	return c, &sleeps
+}
+
+func TestClientRetriesServerErrors(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-RateLimit-Limit", "200,2000")
+		w.Header().Set("X-RateLimit-Usage", "5,50")
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("[]"))
+	}))
+	defer srv.Close()
+
+	c, sleeps := newTestClient(srv.URL)
+	if _, err := GetActivities(c, ReqParams{Page: 1, PerPage: 10}); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if calls != 3 || len(*sleeps) != 2 {
+		t.Errorf("Incorrect retries. Found(%d calls, %d sleeps), Expected(3 calls, 2 sleeps)", calls, len(*sleeps))
+	}
+	if (*sleeps)[1] <= (*sleeps)[0]/2 {
+		t.Errorf("Expected backoff to grow. Found(%v)", *sleeps)
+	}
+	if rl := c.RateLimit(); rl.ShortTermUsage != 5 || rl.DailyUsage != 50 {
+		t.Errorf("Incorrect rate limit usage. Found(%+v)", rl)
+	}
+}
+
+func TestClientGivesUpAfterRetries(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	c, _ := newTestClient(srv.URL)
+	if _, err := GetActivities(c, ReqParams{Page: 1, PerPage: 10}); err == nil {
+		t.Errorf("Expected an error after all retries failed")
+	}
+	if calls != 4 {
+		t.Errorf("Incorrect number of requests. Found(%d), Expected(%d)", calls, 4)
+	}
+}
+
+func TestClientDailyRateLimit(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-RateLimit-Limit", "200,2000")
+		w.Header().Set("X-RateLimit-Usage", "20,2001")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	c, sleeps := newTestClient(srv.URL)
+	for i := 0; i < 2; i++ {
+		_, err := GetActivities(c, ReqParams{Page: 1, PerPage: 10})
+		if !errors.Is(err, ErrDailyRateLimit) {
+			t.Errorf("Expected a daily rate limit error. Found(%v)", err)
+		}
+	}
+	if calls != 1 || len(*sleeps) != 0 {
+		t.Errorf("Expected no retries once the daily limit is reached. Found(%d calls, %d sleeps)", calls, len(*sleeps))
+	}
+}
+
+func TestClientWaitsForShortTermWindow(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-RateLimit-Limit", "200,2000")
+		if calls == 1 {
+			w.Header().Set("X-RateLimit-Usage", "201,400")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("X-RateLimit-Usage", "1,401")
+		w.Write([]byte("[]"))
+	}))
+	defer srv.Close()
+
+	c, sleeps := newTestClient(srv.URL)
+	if _, err := GetActivities(c, ReqParams{Page: 1, PerPage: 10}); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	if len(*sleeps) == 0 || (*sleeps)[0] > 23*time.Minute {
+		t.Errorf("Expected a wait until the next 15 minute window. Found(%v)", *sleeps)
+	}
+}
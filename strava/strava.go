@@ -4,11 +4,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -28,11 +28,65 @@ type Activity struct {
 		Id              string `json:"id"`
 		SummaryPolyline string `json:"summary_polyline"`
 	} `json:"map"`
+	// The following are only returned by GetActivity
+	SplitsStandard []Split      `json:"splits_standard,omitempty"`
+	SplitsMetric   []Split      `json:"splits_metric,omitempty"`
+	Laps           []Lap        `json:"laps,omitempty"`
+	BestEfforts    []BestEffort `json:"best_efforts,omitempty"`
+	// Raw is the activity json as returned by strava
+	Raw json.RawMessage `json:"-"`
 }
 
-// IsRace will return true for running race events
+// Split is a per mile (standard) or per kilometer (metric) split of an activity
+type Split struct {
+	Split               uint16  `json:"split"`
+	Distance            float64 `json:"distance"`
+	ElapsedTime         uint32  `json:"elapsed_time"`
+	MovingTime          uint32  `json:"moving_time"`
+	ElevationDifference float64 `json:"elevation_difference"`
+	AverageSpeed        float64 `json:"average_speed"`
+	AverageHeartrate    float64 `json:"average_heartrate"`
+}
+
+// Lap is a lap recorded by the device, either manually or automatically
+type Lap struct {
+	Id                 uint64  `json:"id"`
+	Name               string  `json:"name"`
+	LapIndex           uint16  `json:"lap_index"`
+	Distance           float64 `json:"distance"`
+	ElapsedTime        uint32  `json:"elapsed_time"`
+	MovingTime         uint32  `json:"moving_time"`
+	TotalElevationGain float64 `json:"total_elevation_gain"`
+	AverageSpeed       float64 `json:"average_speed"`
+	AverageHeartrate   float64 `json:"average_heartrate"`
+}
+
+// BestEffort is the fastest time for a standard distance within an activity, e.g. 5k
+type BestEffort struct {
+	Id          uint64  `json:"id"`
+	Name        string  `json:"name"`
+	Distance    float64 `json:"distance"`
+	ElapsedTime uint32  `json:"elapsed_time"`
+	MovingTime  uint32  `json:"moving_time"`
+	PrRank      uint8   `json:"pr_rank"`
+}
+
+// UnmarshalJSON decodes the activity and keeps a copy of the raw json
+func (a *Activity) UnmarshalJSON(b []byte) error {
+	type activity Activity
+	var v activity
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*a = Activity(v)
+	a.Raw = append(json.RawMessage(nil), b...)
+	return nil
+}
+
+// IsRace will return true for running race events.
+// Use a RacePolicy to select races of other sports.
 func (a *Activity) IsRace() bool {
-	return a.SportType == "Run" && a.WorkoutType == 1
+	return DefaultRacePolicy().Match(*a)
 }
 
 var re = regexp.MustCompile("[^a-z0-9]+")
@@ -58,9 +112,98 @@ type AuthTokenResp struct {
 	Athlete      Athlete `json:"athlete,omitempty"`
 }
 
+// Option configures the urls and transport used by a Client or an Authorization
+type Option func(*options)
+
+type options struct {
+	apiURL     string
+	oauthURL   string
+	transport  http.RoundTripper
+	maxRetries int
+}
+
+func newOptions(opts []Option) options {
+	o := options{apiURL: api_uri, oauthURL: oauth_uri, maxRetries: 3}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithAPIURL sets the base url for strava api requests
+func WithAPIURL(u string) Option {
+	return func(o *options) {
+		o.apiURL = strings.TrimSuffix(u, "/")
+	}
+}
+
+// WithOAuthURL sets the base url for strava oauth requests
+func WithOAuthURL(u string) Option {
+	return func(o *options) {
+		o.oauthURL = strings.TrimSuffix(u, "/")
+	}
+}
+
+// WithTransport sets the http.RoundTripper used to send requests
+func WithTransport(rt http.RoundTripper) Option {
+	return func(o *options) {
+		o.transport = rt
+	}
+}
+
+// WithRetries sets how many times a request is retried after a
+// rate limited (429) or server error (5xx) response
+func WithRetries(n int) Option {
+	return func(o *options) {
+		o.maxRetries = n
+	}
+}
+
 type Client struct {
-	httpclient  http.Client
-	accessToken string
+	httpclient http.Client
+	tokens     TokenSource
+	apiURL     string
+	maxRetries int
+	sleep      func(time.Duration)
+
+	mu        sync.Mutex
+	rateLimit RateLimit
+}
+
+// RateLimit returns the api usage reported by the latest strava response
+func (c *Client) RateLimit() RateLimit {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rateLimit
+}
+
+func (c *Client) updateRateLimit(h http.Header) RateLimit {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if rl, ok := parseRateLimit(h, time.Now()); ok {
+		c.rateLimit = rl
+	}
+	return c.rateLimit
+}
+
+// waitForRateLimit returns an error when the daily quota is known to be used up
+// and waits for the next window when the 15 minute quota is used up.
+func (c *Client) waitForRateLimit(req *http.Request) error {
+	rl := c.RateLimit()
+	now := time.Now()
+	if rl.DailyExceeded() && now.Before(rl.DailyReset()) {
+		return &APIError{
+			StatusCode: http.StatusTooManyRequests,
+			Status:     fmt.Sprintf("%d %s", http.StatusTooManyRequests, http.StatusText(http.StatusTooManyRequests)),
+			Message:    "request not sent, the daily rate limit is used up",
+			Path:       req.URL.Path,
+			RateLimit:  rl,
+		}
+	}
+	if rl.ShortTermExceeded() && now.Before(rl.ShortTermReset()) {
+		c.sleep(rl.ShortTermReset().Sub(now) + time.Second)
+	}
+	return nil
 }
 
 func (c *Client) Get(url string, headers map[string]string) (*http.Response, error) {
@@ -74,24 +217,55 @@ func (c *Client) Get(url string, headers map[string]string) (*http.Response, err
 	return c.Do(req)
 }
 
+// Do sends the request with a valid bearer token from the client's TokenSource.
+// Rate limited and server error responses are retried with backoff. Requests
+// must not have a body so they can be sent again.
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
-	resp, err := c.httpclient.Do(req)
-	if err != nil {
-		return nil, err
+	for attempt := 0; ; attempt++ {
+		if err := c.waitForRateLimit(req); err != nil {
+			return nil, err
+		}
+
+		t, err := c.tokens.Token()
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", t.AccessToken))
+
+		resp, err := c.httpclient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		rl := c.updateRateLimit(resp.Header)
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+
+		apiErr := newAPIError(resp, rl)
+		if apiErr.Is(ErrDailyRateLimit) || !isRetryable(resp.StatusCode) || attempt >= c.maxRetries {
+			return nil, apiErr
+		}
+		c.sleep(retryDelay(attempt, resp, rl, time.Now()))
 	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf(resp.Status)
-	}
-	return resp, nil
 }
 
 // NewClient creates and returns a new Client
 // which contains an httpclient with a specified Timeout
 // and an accessToken for strava api requests
-func NewClient(t string) *Client {
+func NewClient(t string, opts ...Option) *Client {
+	return NewTokenClient(StaticTokenSource(t), opts...)
+}
+
+// NewTokenClient creates and returns a new Client that gets
+// the access token for every request from a TokenSource
+func NewTokenClient(ts TokenSource, opts ...Option) *Client {
+	o := newOptions(opts)
 	return &Client{
-		httpclient:  http.Client{Timeout: 10 * time.Second},
-		accessToken: t,
+		httpclient: http.Client{Timeout: 10 * time.Second, Transport: o.transport},
+		tokens:     ts,
+		apiURL:     o.apiURL,
+		maxRetries: o.maxRetries,
+		sleep:      time.Sleep,
 	}
 }
 
@@ -100,6 +274,22 @@ type Authorization struct {
 	ClientSecret string
 	RedirectUri  string
 	Scope        string
+	opts         []Option
+}
+
+// Configure sets the options used for oauth requests
+func (a *Authorization) Configure(opts ...Option) {
+	a.opts = opts
+}
+
+func (a *Authorization) oauthURL() string {
+	return newOptions(a.opts).oauthURL
+}
+
+func (a *Authorization) post(path string, body []byte) (*http.Response, error) {
+	o := newOptions(a.opts)
+	client := http.Client{Timeout: 10 * time.Second, Transport: o.transport}
+	return client.Post(fmt.Sprintf("%s%s", o.oauthURL, path), "application/json", bytes.NewBuffer(body))
 }
 
 // Url returns a Url for authentication
@@ -109,7 +299,7 @@ func (a *Authorization) Url() string {
 	qs.Set("redirect_uri", a.RedirectUri)
 	qs.Set("response_type", "code")
 	qs.Set("scope", a.Scope)
-	return fmt.Sprintf("%s/authorize?%s", oauth_uri, qs.Encode())
+	return fmt.Sprintf("%s/authorize?%s", a.oauthURL(), qs.Encode())
 }
 
 func (a *Authorization) ReqAccessToken(code string) (AuthTokenResp, error) {
@@ -132,19 +322,18 @@ func (a *Authorization) ReqAccessToken(code string) (AuthTokenResp, error) {
 		return tkResp, fmt.Errorf("Unable to create request body for requesting access token. %s", err)
 	}
 
-	resp, err := http.Post(fmt.Sprintf("%s/token", oauth_uri), "application/json", bytes.NewBuffer(reqBody))
+	resp, err := a.post("/token", reqBody)
 	if err != nil {
 		return tkResp, fmt.Errorf("Unable to request access token. %s", err)
 	}
+	if resp.StatusCode != http.StatusOK {
+		return tkResp, newAPIError(resp, RateLimit{})
+	}
 	defer resp.Body.Close()
 
 	if err := json.NewDecoder(resp.Body).Decode(&tkResp); err != nil {
 		return tkResp, fmt.Errorf("Failed to decode response body. %s", err)
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		return tkResp, fmt.Errorf(resp.Status)
-	}
 	return tkResp, nil
 }
 
@@ -160,35 +349,31 @@ func (a *Authorization) RefreshToken(refreshToken string) (AuthTokenResp, error)
 
 	reqBody, err := json.Marshal(payload)
 	if err != nil {
-		log.Println(err)
-		return tkResp, nil
+		return tkResp, fmt.Errorf("Unable to create request body for refreshing access token. %s", err)
 	}
 
-	resp, err := http.Post(fmt.Sprintf("%s/token", oauth_uri), "application/json", bytes.NewBuffer(reqBody))
+	resp, err := a.post("/token", reqBody)
 	if err != nil {
 		return tkResp, fmt.Errorf("Unable to request access token. %s", err)
 	}
+	if resp.StatusCode != http.StatusOK {
+		return tkResp, newAPIError(resp, RateLimit{})
+	}
 	defer resp.Body.Close()
 
 	if err := json.NewDecoder(resp.Body).Decode(&tkResp); err != nil {
 		return tkResp, fmt.Errorf("Failed to decode response body. %s", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return tkResp, fmt.Errorf(resp.Status)
-	}
-
 	return tkResp, nil
 }
 
-// GetActivity will return a strava activity for an authorized user
+// GetActivity will return a detailed strava activity for an authorized user.
+// Detailed activities include splits, laps and best efforts.
 func GetActivity(c *Client, id uint64) (Activity, error) {
 	var activity Activity
 
-	headers := map[string]string{
-		"Authorization": fmt.Sprintf("Bearer %s", c.accessToken),
-	}
-	resp, err := c.Get(fmt.Sprintf("%s/activities/%d", api_uri, id), headers)
+	resp, err := c.Get(fmt.Sprintf("%s/activities/%d?include_all_efforts=true", c.apiURL, id), nil)
 	if err != nil {
 		return activity, err
 	}
@@ -200,10 +385,15 @@ func GetActivity(c *Client, id uint64) (Activity, error) {
 	return activity, nil
 }
 
+// ReqParams are the paging and time window params for GetActivities.
+// After and Before are epoch timestamps; Before is only sent when set.
+// Strava sorts activities oldest first when only After is sent and
+// newest first when Before is sent.
 type ReqParams struct {
 	Page    uint16
 	PerPage uint8
 	After   int64
+	Before  int64
 }
 
 // GetActivities will return an array of strava activities for an authorized user
@@ -214,12 +404,12 @@ func GetActivities(c *Client, rp ReqParams) ([]Activity, error) {
 	qs.Set("page", fmt.Sprintf("%d", rp.Page))
 	qs.Set("per_page", fmt.Sprintf("%d", rp.PerPage))
 	qs.Set("after", fmt.Sprintf("%d", rp.After))
-
-	headers := map[string]string{
-		"Authorization": fmt.Sprintf("Bearer %s", c.accessToken),
+	if rp.Before > 0 {
+		qs.Set("before", fmt.Sprintf("%d", rp.Before))
 	}
-	url := fmt.Sprintf("%s/athlete/activities?%s", api_uri, qs.Encode())
-	resp, err := c.Get(url, headers)
+
+	url := fmt.Sprintf("%s/athlete/activities?%s", c.apiURL, qs.Encode())
+	resp, err := c.Get(url, nil)
 	if err != nil {
 		return activities, err
 	}
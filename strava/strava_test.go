@@ -1,6 +1,7 @@
 package strava
 
 import (
+	"encoding/json"
 	"net/url"
 	"testing"
 	"time"
@@ -16,8 +17,12 @@ func TestNewClient(t *testing.T) {
 		t.Errorf("Incorrect client timeout value. Found(%d), Expected(%d)", resultTimeout, expectedTimeout)
 	}
 
-	if c.accessToken != token {
-		t.Errorf("Incorrect access token. Found(%s), Expected(%s)", c.accessToken, token)
+	tk, err := c.tokens.Token()
+	if err != nil {
+		t.Errorf("Unexpected token source error. %s", err)
+	}
+	if tk.AccessToken != token {
+		t.Errorf("Incorrect access token. Found(%s), Expected(%s)", tk.AccessToken, token)
 	}
 }
 
@@ -77,3 +82,71 @@ func TestNameSlugified(t *testing.T) {
 		}
 	}
 }
+
+func TestRacePolicyMatch(t *testing.T) {
+	policy := RacePolicy{
+		SportTypes:   []string{"Run", "TrailRun", "Ride"},
+		WorkoutTypes: []uint8{WorkoutTypeRunRace, WorkoutTypeRideRace},
+		NameKeywords: []string{"Marathon", "triathlon"},
+		IncludeIds:   []uint64{10},
+		ExcludeIds:   []uint64{11},
+	}
+
+	testCases := []struct {
+		input    Activity
+		expected bool
+	}{
+		{Activity{Id: 1, Name: "Brooklyn Half", SportType: "Run", WorkoutType: 1}, true},
+		{Activity{Id: 2, Name: "Mountain Race", SportType: "TrailRun", WorkoutType: 1}, true},
+		{Activity{Id: 3, Name: "Gran Fondo", SportType: "Ride", WorkoutType: 11}, true},
+		{Activity{Id: 4, Name: "Easy Run", SportType: "Run", WorkoutType: 0}, false},
+		{Activity{Id: 5, Name: "Virtual Marathon", SportType: "Run", WorkoutType: 0}, true},
+		{Activity{Id: 6, Name: "Sprint Triathlon Swim", SportType: "Swim", WorkoutType: 0}, false},
+		{Activity{Id: 10, Name: "Swim Leg", SportType: "Swim", WorkoutType: 0}, true},
+		{Activity{Id: 11, Name: "NYC Marathon", SportType: "Run", WorkoutType: 1}, false},
+	}
+
+	for _, tc := range testCases {
+		if policy.Match(tc.input) != tc.expected {
+			t.Errorf("Match(%s) has unexpected value. Found(%t), Expected(%t)", tc.input.Name, !tc.expected, tc.expected)
+		}
+	}
+}
+
+func TestIsRace(t *testing.T) {
+	testCases := []struct {
+		input    Activity
+		expected bool
+	}{
+		{Activity{SportType: "Run", WorkoutType: 1}, true},
+		{Activity{SportType: "Run", WorkoutType: 2}, false},
+		{Activity{SportType: "TrailRun", WorkoutType: 1}, false},
+		{Activity{SportType: "Ride", WorkoutType: 11}, false},
+	}
+
+	for _, tc := range testCases {
+		if tc.input.IsRace() != tc.expected {
+			t.Errorf("IsRace(%s, %d) has unexpected value. Expected(%t)", tc.input.SportType, tc.input.WorkoutType, tc.expected)
+		}
+	}
+}
+
+func TestActivityKeepsRawJSON(t *testing.T) {
+	input := `[{"id":5,"name":"Queens 10K","sport_type":"Run","workout_type":1,"kudos_count":12,"map":{"summary_polyline":"abc"}}]`
+	var activities []Activity
+	if err := json.Unmarshal([]byte(input), &activities); err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	a := activities[0]
+	if a.Id != 5 || a.Name != "Queens 10K" || a.Map.SummaryPolyline != "abc" {
+		t.Errorf("Activity was not decoded. Found(%+v)", a)
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(a.Raw, &raw); err != nil {
+		t.Fatalf("Raw json is invalid. %s", err)
+	}
+	if raw["kudos_count"] != float64(12) {
+		t.Errorf("Raw json is missing fields. Found(%s)", a.Raw)
+	}
+}
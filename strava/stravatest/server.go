@@ -0,0 +1,385 @@
+// Package stravatest provides a fake Strava server for testing code
+// that talks to the Strava api without network access.
+package stravatest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ddominguez/run-david-run/strava"
+)
+
+// Server is a fake Strava api and oauth server backed by an httptest.Server.
+// It serves the following endpoints:
+//
+//	GET  /api/v3/athlete/activities
+//	GET  /api/v3/activities/{id}
+//	GET  /api/v3/activities/{id}/streams
+//	POST /oauth/token
+type Server struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	athlete      strava.Athlete
+	activities   map[uint64]strava.Activity
+	streams      map[uint64]strava.Streams
+	code         string
+	accessToken  string
+	refreshToken string
+	expiresAt    uint64
+	requests     map[string]int
+	refreshes    int
+	shortLimit   int
+	dailyLimit   int
+	shortUsage   int
+	dailyUsage   int
+	failures     []int
+}
+
+// NewServer starts and returns a new Server. The caller should call Close when finished.
+func NewServer() *Server {
+	s := &Server{
+		athlete:      strava.Athlete{Id: 1, FirstName: "Test", LastName: "Runner"},
+		activities:   map[uint64]strava.Activity{},
+		streams:      map[uint64]strava.Streams{},
+		code:         "test-code",
+		accessToken:  "test-access-token",
+		refreshToken: "test-refresh-token",
+		expiresAt:    uint64(time.Now().Add(6 * time.Hour).Unix()),
+		requests:     map[string]int{},
+		shortLimit:   200,
+		dailyLimit:   2000,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v3/athlete/activities", s.handleActivities)
+	mux.HandleFunc("GET /api/v3/activities/{id}", s.handleActivity)
+	mux.HandleFunc("GET /api/v3/activities/{id}/streams", s.handleStreams)
+	mux.HandleFunc("POST /oauth/token", s.handleToken)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// APIURL returns the base url of the fake strava api
+func (s *Server) APIURL() string {
+	return s.URL + "/api/v3"
+}
+
+// OAuthURL returns the base url of the fake strava oauth endpoints
+func (s *Server) OAuthURL() string {
+	return s.URL + "/oauth"
+}
+
+// Options returns the strava options needed to send requests to this server
+func (s *Server) Options() []strava.Option {
+	return []strava.Option{
+		strava.WithAPIURL(s.APIURL()),
+		strava.WithOAuthURL(s.OAuthURL()),
+		strava.WithTransport(s.Client().Transport),
+	}
+}
+
+// Code returns the authorization code accepted by the token endpoint
+func (s *Server) Code() string {
+	return s.code
+}
+
+// Token returns the currently valid token
+func (s *Server) Token() strava.Token {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return strava.Token{AccessToken: s.accessToken, RefreshToken: s.refreshToken, ExpiresAt: s.expiresAt}
+}
+
+// ExpireToken marks the current access token as expired. Requests made with it
+// are still accepted so clients must notice the expiry on their own.
+func (s *Server) ExpireToken() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expiresAt = uint64(time.Now().Add(-time.Minute).Unix())
+}
+
+// SetRateLimit sets the 15 minute and daily request quotas and resets the usage.
+// Api requests over either quota get a 429 response.
+func (s *Server) SetRateLimit(shortLimit, dailyLimit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shortLimit, s.dailyLimit = shortLimit, dailyLimit
+	s.shortUsage, s.dailyUsage = 0, 0
+}
+
+// FailNext makes the next api requests respond with the given status codes, in order
+func (s *Server) FailNext(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, statuses...)
+}
+
+// SetAthlete sets the athlete returned by the authorization code exchange
+func (s *Server) SetAthlete(a strava.Athlete) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.athlete = a
+}
+
+// AddActivities stores activities that will be returned by the api
+func (s *Server) AddActivities(activities ...strava.Activity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range activities {
+		s.activities[a.Id] = a
+	}
+}
+
+// SetStreams stores the streams returned for an activity
+func (s *Server) SetStreams(id uint64, streams strava.Streams) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streams[id] = streams
+}
+
+// RemoveActivity deletes a stored activity
+func (s *Server) RemoveActivity(id uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.activities, id)
+}
+
+// Requests returns how many requests were received for a path pattern,
+// e.g. "GET /api/v3/athlete/activities"
+func (s *Server) Requests(pattern string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[pattern]
+}
+
+// Refreshes returns how many times a refresh token was exchanged
+func (s *Server) Refreshes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.refreshes
+}
+
+type faultError struct {
+	Resource string `json:"resource"`
+	Field    string `json:"field"`
+	Code     string `json:"code"`
+}
+
+type fault struct {
+	Message string       `json:"message"`
+	Errors  []faultError `json:"errors"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeFault(w http.ResponseWriter, status int, message, resource, field, code string) {
+	writeJSON(w, status, fault{
+		Message: message,
+		Errors:  []faultError{{Resource: resource, Field: field, Code: code}},
+	})
+}
+
+// authorized records the request, applies the rate limit and checks the bearer token.
+// It must be called with s.mu held.
+func (s *Server) authorized(w http.ResponseWriter, r *http.Request, pattern string) bool {
+	s.requests[pattern]++
+	s.shortUsage++
+	s.dailyUsage++
+	w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d,%d", s.shortLimit, s.dailyLimit))
+	w.Header().Set("X-RateLimit-Usage", fmt.Sprintf("%d,%d", s.shortUsage, s.dailyUsage))
+	if s.shortUsage > s.shortLimit || s.dailyUsage > s.dailyLimit {
+		writeFault(w, http.StatusTooManyRequests, "Rate Limit Exceeded", "Application", "rate limit", "exceeded")
+		return false
+	}
+	if len(s.failures) > 0 {
+		status := s.failures[0]
+		s.failures = s.failures[1:]
+		writeFault(w, status, http.StatusText(status), "Application", "", "")
+		return false
+	}
+	if r.Header.Get("Authorization") != "Bearer "+s.accessToken {
+		writeFault(w, http.StatusUnauthorized, "Authorization Error", "Athlete", "access_token", "invalid")
+		return false
+	}
+	return true
+}
+
+func startEpoch(a strava.Activity) int64 {
+	t, err := time.Parse(time.RFC3339, a.StartDateLocal)
+	if err != nil {
+		return 0
+	}
+	return t.Unix()
+}
+
+func queryInt(r *http.Request, key string, fallback int64) int64 {
+	v, err := strconv.ParseInt(r.URL.Query().Get(key), 10, 64)
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+func (s *Server) handleActivities(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.authorized(w, r, "GET /api/v3/athlete/activities") {
+		return
+	}
+
+	page := queryInt(r, "page", 1)
+	perPage := queryInt(r, "per_page", 30)
+	after := queryInt(r, "after", 0)
+	before := queryInt(r, "before", 0)
+	if page < 1 || perPage < 1 {
+		writeFault(w, http.StatusBadRequest, "Bad Request", "Activities", "page", "invalid")
+		return
+	}
+
+	var res []strava.Activity
+	for _, a := range s.activities {
+		epoch := startEpoch(a)
+		if epoch <= after || (before > 0 && epoch >= before) {
+			continue
+		}
+		// summaries don't include the detailed activity data
+		a.SplitsStandard, a.SplitsMetric, a.Laps, a.BestEfforts = nil, nil, nil, nil
+		res = append(res, a)
+	}
+	// strava returns activities oldest first when only `after` is set
+	// and newest first otherwise
+	ascending := before == 0 && r.URL.Query().Has("after")
+	sort.Slice(res, func(i, j int) bool {
+		if ascending {
+			return startEpoch(res[i]) < startEpoch(res[j])
+		}
+		return startEpoch(res[i]) > startEpoch(res[j])
+	})
+
+	start := (page - 1) * perPage
+	if start >= int64(len(res)) {
+		writeJSON(w, http.StatusOK, []strava.Activity{})
+		return
+	}
+	end := min(start+perPage, int64(len(res)))
+	writeJSON(w, http.StatusOK, res[start:end])
+}
+
+func (s *Server) handleActivity(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.authorized(w, r, "GET /api/v3/activities/{id}") {
+		return
+	}
+
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeFault(w, http.StatusNotFound, "Record Not Found", "Activity", "id", "not found")
+		return
+	}
+	a, ok := s.activities[id]
+	if !ok {
+		writeFault(w, http.StatusNotFound, "Record Not Found", "Activity", "id", "not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, a)
+}
+
+func (s *Server) handleStreams(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.authorized(w, r, "GET /api/v3/activities/{id}/streams") {
+		return
+	}
+
+	id, _ := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if _, ok := s.activities[id]; !ok {
+		writeFault(w, http.StatusNotFound, "Record Not Found", "Activity", "id", "not found")
+		return
+	}
+
+	st := s.streams[id]
+	all := map[string]interface{}{
+		strava.StreamLatLng:         st.LatLng,
+		strava.StreamTime:           st.Time,
+		strava.StreamDistance:       st.Distance,
+		strava.StreamAltitude:       st.Altitude,
+		strava.StreamHeartrate:      st.Heartrate,
+		strava.StreamCadence:        st.Cadence,
+		strava.StreamVelocitySmooth: st.VelocitySmooth,
+	}
+	res := map[string]interface{}{}
+	for _, key := range strings.Split(r.URL.Query().Get("keys"), ",") {
+		data, ok := all[key]
+		if !ok || reflect.ValueOf(data).Len() == 0 {
+			continue
+		}
+		res[key] = map[string]interface{}{
+			"type":          key,
+			"data":          data,
+			"series_type":   "distance",
+			"original_size": reflect.ValueOf(data).Len(),
+			"resolution":    "high",
+		}
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests["POST /oauth/token"]++
+
+	var payload map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeFault(w, http.StatusBadRequest, "Bad Request", "Application", "body", "invalid")
+		return
+	}
+
+	switch payload["grant_type"] {
+	case "authorization_code":
+		if payload["code"] != s.code {
+			writeFault(w, http.StatusBadRequest, "Bad Request", "AuthorizationCode", "code", "invalid")
+			return
+		}
+		writeJSON(w, http.StatusOK, strava.AuthTokenResp{
+			TokenType:    "Bearer",
+			ExpiresAt:    s.expiresAt,
+			ExpiresIn:    uint64(time.Until(time.Unix(int64(s.expiresAt), 0)).Seconds()),
+			RefreshToken: s.refreshToken,
+			AccessToken:  s.accessToken,
+			Athlete:      s.athlete,
+		})
+	case "refresh_token":
+		if payload["refresh_token"] != s.refreshToken {
+			writeFault(w, http.StatusBadRequest, "Bad Request", "RefreshToken", "refresh_token", "invalid")
+			return
+		}
+		s.refreshes++
+		s.accessToken = fmt.Sprintf("test-access-token-%d", s.refreshes)
+		s.refreshToken = fmt.Sprintf("test-refresh-token-%d", s.refreshes)
+		s.expiresAt = uint64(time.Now().Add(6 * time.Hour).Unix())
+		writeJSON(w, http.StatusOK, strava.AuthTokenResp{
+			TokenType:    "Bearer",
+			ExpiresAt:    s.expiresAt,
+			ExpiresIn:    6 * 60 * 60,
+			RefreshToken: s.refreshToken,
+			AccessToken:  s.accessToken,
+		})
+	default:
+		writeFault(w, http.StatusBadRequest, "Bad Request", "Application", "grant_type", "invalid")
+	}
+}
@@ -0,0 +1,89 @@
+package strava
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Stream types that can be requested with GetActivityStreams
+const (
+	StreamLatLng         = "latlng"
+	StreamTime           = "time"
+	StreamDistance       = "distance"
+	StreamAltitude       = "altitude"
+	StreamHeartrate      = "heartrate"
+	StreamCadence        = "cadence"
+	StreamVelocitySmooth = "velocity_smooth"
+)
+
+// AllStreams is every stream type supported by GetActivityStreams
+var AllStreams = []string{
+	StreamLatLng, StreamTime, StreamDistance, StreamAltitude,
+	StreamHeartrate, StreamCadence, StreamVelocitySmooth,
+}
+
+// Streams holds the recorded data points of an activity. Every stream has a
+// value per data point; streams that were not recorded are empty.
+type Streams struct {
+	LatLng         [][2]float64
+	Time           []int
+	Distance       []float64
+	Altitude       []float64
+	Heartrate      []int
+	Cadence        []int
+	VelocitySmooth []float64
+}
+
+// Len returns the number of data points
+func (s Streams) Len() int {
+	return max(len(s.LatLng), len(s.Time), len(s.Distance), len(s.Altitude),
+		len(s.Heartrate), len(s.Cadence), len(s.VelocitySmooth))
+}
+
+// GetActivityStreams will return the requested streams of an activity.
+// All streams are requested when no keys are given.
+func GetActivityStreams(c *Client, id uint64, keys ...string) (Streams, error) {
+	var streams Streams
+	if len(keys) == 0 {
+		keys = AllStreams
+	}
+
+	qs := url.Values{}
+	qs.Set("keys", strings.Join(keys, ","))
+	qs.Set("key_by_type", "true")
+
+	resp, err := c.Get(fmt.Sprintf("%s/activities/%d/streams?%s", c.apiURL, id, qs.Encode()), nil)
+	if err != nil {
+		return streams, err
+	}
+	defer resp.Body.Close()
+
+	var res map[string]struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return streams, err
+	}
+
+	targets := map[string]interface{}{
+		StreamLatLng:         &streams.LatLng,
+		StreamTime:           &streams.Time,
+		StreamDistance:       &streams.Distance,
+		StreamAltitude:       &streams.Altitude,
+		StreamHeartrate:      &streams.Heartrate,
+		StreamCadence:        &streams.Cadence,
+		StreamVelocitySmooth: &streams.VelocitySmooth,
+	}
+	for key, s := range res {
+		target, ok := targets[key]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal(s.Data, target); err != nil {
+			return streams, fmt.Errorf("unable to decode %s stream: %w", key, err)
+		}
+	}
+	return streams, nil
+}
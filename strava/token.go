@@ -0,0 +1,99 @@
+package strava
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ExpiryDelta is how long before the actual expiration an access token
+// is treated as expired, so a request never goes out with a token that
+// expires while it is in flight.
+const ExpiryDelta = 5 * time.Minute
+
+// Token holds an access token and the data needed to refresh it.
+type Token struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    uint64
+}
+
+// Expired returns true when the token is expired or will expire within ExpiryDelta.
+// A token without expiry is expired when it can be refreshed, e.g. a saved
+// token without expires_at, and never expires otherwise.
+func (t Token) Expired() bool {
+	if t.ExpiresAt == 0 {
+		return t.RefreshToken != ""
+	}
+	return time.Now().Add(ExpiryDelta).Unix() >= int64(t.ExpiresAt)
+}
+
+// TokenSource returns an access token that can be used for strava api requests
+type TokenSource interface {
+	Token() (Token, error)
+}
+
+type staticTokenSource struct {
+	token Token
+}
+
+func (s staticTokenSource) Token() (Token, error) {
+	return s.token, nil
+}
+
+// StaticTokenSource returns a TokenSource that always returns the same access token
+func StaticTokenSource(accessToken string) TokenSource {
+	return staticTokenSource{token: Token{AccessToken: accessToken}}
+}
+
+// tokenRefresher is implemented by Authorization
+type tokenRefresher interface {
+	RefreshToken(refreshToken string) (AuthTokenResp, error)
+}
+
+type refreshingTokenSource struct {
+	mu        sync.Mutex
+	token     Token
+	refresher tokenRefresher
+	onRefresh func(Token) error
+}
+
+// Token returns the current token, refreshing it first when it is expired
+// or about to expire.
+func (s *refreshingTokenSource) Token() (Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.token.Expired() {
+		return s.token, nil
+	}
+
+	resp, err := s.refresher.RefreshToken(s.token.RefreshToken)
+	if err != nil {
+		return Token{}, fmt.Errorf("unable to refresh access token: %w", err)
+	}
+
+	t := Token{
+		AccessToken:  resp.AccessToken,
+		RefreshToken: resp.RefreshToken,
+		ExpiresAt:    resp.ExpiresAt,
+	}
+	if s.onRefresh != nil {
+		if err := s.onRefresh(t); err != nil {
+			return Token{}, fmt.Errorf("unable to save refreshed access token: %w", err)
+		}
+	}
+	s.token = t
+	return s.token, nil
+}
+
+// NewTokenSource returns a TokenSource that starts with token t and uses
+// the authorization to refresh it whenever it is expired or about to expire.
+// onRefresh is called with every new token so it can be persisted.
+func NewTokenSource(a *Authorization, t Token, onRefresh func(Token) error) TokenSource {
+	return &refreshingTokenSource{
+		token:     t,
+		refresher: a,
+		onRefresh: onRefresh,
+	}
+}
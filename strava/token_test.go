@@ -0,0 +1,82 @@
+package strava
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type fakeRefresher struct {
+	calls int
+	resp  AuthTokenResp
+	err   error
+}
+
+func (f *fakeRefresher) RefreshToken(refreshToken string) (AuthTokenResp, error) {
+	f.calls++
+	return f.resp, f.err
+}
+
+func TestTokenExpired(t *testing.T) {
+	now := time.Now().Unix()
+	testCases := []struct {
+		expiresAt    int64
+		refreshToken string
+		expected     bool
+	}{
+		{0, "", false},
+		{0, "r", true},
+		{now - 60, "", true},
+		{now + 60, "", true},
+		{now + int64(ExpiryDelta.Seconds()) + 60, "", false},
+		{now + int64(ExpiryDelta.Seconds()) + 60, "r", false},
+	}
+
+	for _, tc := range testCases {
+		tk := Token{AccessToken: "a", RefreshToken: tc.refreshToken, ExpiresAt: uint64(tc.expiresAt)}
+		if tk.Expired() != tc.expected {
+			t.Errorf("Expired() has unexpected value for expires_at %d and refresh token %q. Found(%t), Expected(%t)", tc.expiresAt, tc.refreshToken, tk.Expired(), tc.expected)
+		}
+	}
+}
+
+func TestRefreshingTokenSource(t *testing.T) {
+	newExpiry := uint64(time.Now().Add(6 * time.Hour).Unix())
+	f := &fakeRefresher{resp: AuthTokenResp{AccessToken: "new", RefreshToken: "newRefresh", ExpiresAt: newExpiry}}
+	var saved Token
+	ts := &refreshingTokenSource{
+		token:     Token{AccessToken: "old", RefreshToken: "oldRefresh", ExpiresAt: uint64(time.Now().Unix())},
+		refresher: f,
+		onRefresh: func(tk Token) error {
+			saved = tk
+			return nil
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		tk, err := ts.Token()
+		if err != nil {
+			t.Fatalf("Unexpected token error. %s", err)
+		}
+		if tk.AccessToken != "new" {
+			t.Errorf("Incorrect access token. Found(%s), Expected(%s)", tk.AccessToken, "new")
+		}
+	}
+	if f.calls != 1 {
+		t.Errorf("Incorrect number of refresh calls. Found(%d), Expected(%d)", f.calls, 1)
+	}
+	if saved.RefreshToken != "newRefresh" || saved.ExpiresAt != newExpiry {
+		t.Errorf("Refreshed token was not saved. Found(%+v)", saved)
+	}
+}
+
+func TestRefreshingTokenSourceError(t *testing.T) {
+	f := &fakeRefresher{err: fmt.Errorf("400 Bad Request")}
+	ts := &refreshingTokenSource{
+		token:     Token{AccessToken: "old", RefreshToken: "oldRefresh", ExpiresAt: 1},
+		refresher: f,
+	}
+	if _, err := ts.Token(); err == nil {
+		t.Errorf("Expected an error when the refresh fails")
+	}
+}
@@ -0,0 +1,95 @@
+// Package streams encodes activity streams in a compact binary format.
+//
+// Values are quantized to a fixed number of decimals, delta encoded and
+// written as zigzag varints, so slowly changing streams like gps
+// coordinates or elapsed time take one or two bytes per value.
+// A stream can have several values per data point, e.g. latitude and
+// longitude, which are delta encoded separately.
+//
+// The format is:
+//
+//	version   byte
+//	precision uvarint  number of decimals kept
+//	dims      uvarint  values per data point
+//	count     uvarint  number of data points
+//	deltas    varint   count*dims deltas, point by point
+package streams
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+const version = 1
+
+// ErrInvalid is returned when decoding data that is not an encoded stream
+var ErrInvalid = errors.New("invalid encoded stream")
+
+// Encode returns the compact form of values, a flat list of data points with
+// dims values each, keeping precision decimals.
+func Encode(values []float64, dims int, precision int) ([]byte, error) {
+	if dims < 1 || len(values)%dims != 0 {
+		return nil, fmt.Errorf("%d values can not be split into points of %d", len(values), dims)
+	}
+	if precision < 0 || precision > 9 {
+		return nil, fmt.Errorf("precision %d is out of range", precision)
+	}
+
+	scale := math.Pow10(precision)
+	buf := make([]byte, 0, 8+len(values)*2)
+	buf = append(buf, version)
+	buf = binary.AppendUvarint(buf, uint64(precision))
+	buf = binary.AppendUvarint(buf, uint64(dims))
+	buf = binary.AppendUvarint(buf, uint64(len(values)/dims))
+
+	prev := make([]int64, dims)
+	for i, v := range values {
+		q := int64(math.Round(v * scale))
+		d := i % dims
+		buf = binary.AppendVarint(buf, q-prev[d])
+		prev[d] = q
+	}
+	return buf, nil
+}
+
+// Decode returns the flat list of values and the number of values per data point
+func Decode(data []byte) ([]float64, int, error) {
+	r := bytes.NewReader(data)
+	v, err := r.ReadByte()
+	if err != nil || v != version {
+		return nil, 0, ErrInvalid
+	}
+	precision, err := binary.ReadUvarint(r)
+	if err != nil || precision > 9 {
+		return nil, 0, ErrInvalid
+	}
+	dims, err := binary.ReadUvarint(r)
+	if err != nil || dims < 1 {
+		return nil, 0, ErrInvalid
+	}
+	count, err := binary.ReadUvarint(r)
+	// every value takes at least one byte
+	if err != nil || count*dims > uint64(r.Len()) {
+		return nil, 0, ErrInvalid
+	}
+
+	scale := math.Pow10(int(precision))
+	values := make([]float64, count*dims)
+	prev := make([]int64, dims)
+	for i := range values {
+		delta, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, 0, ErrInvalid
+		}
+		d := i % int(dims)
+		prev[d] += delta
+		values[i] = float64(prev[d]) / scale
+	}
+	if r.Len() != 0 {
+		return nil, 0, ErrInvalid
+	}
+	return values, int(dims), nil
+}
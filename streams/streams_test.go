@@ -0,0 +1,82 @@
+package streams
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	testCases := []struct {
+		name      string
+		values    []float64
+		dims      int
+		precision int
+	}{
+		{"empty", nil, 1, 0},
+		{"time", []float64{0, 1, 2, 5, 6, 7, 3600}, 1, 0},
+		{"altitude", []float64{10.2, 10.4, 9.8, -2.5, 120.1}, 1, 1},
+		{"latlng", []float64{40.748817, -73.985428, 40.748901, -73.985311, 40.749012, -73.985201}, 2, 6},
+		{"velocity", []float64{2.874, 3.011, 0, 3.456}, 1, 3},
+	}
+
+	for _, tc := range testCases {
+		data, err := Encode(tc.values, tc.dims, tc.precision)
+		if err != nil {
+			t.Fatalf("%s: unexpected encode error. %s", tc.name, err)
+		}
+		values, dims, err := Decode(data)
+		if err != nil {
+			t.Fatalf("%s: unexpected decode error. %s", tc.name, err)
+		}
+		if dims != tc.dims || len(values) != len(tc.values) {
+			t.Fatalf("%s: incorrect shape. Found(%d x %d), Expected(%d x %d)", tc.name, len(values), dims, len(tc.values), tc.dims)
+		}
+		tolerance := math.Pow10(-tc.precision) / 2
+		for i := range values {
+			if math.Abs(values[i]-tc.values[i]) > tolerance {
+				t.Errorf("%s: incorrect value %d. Found(%f), Expected(%f)", tc.name, i, values[i], tc.values[i])
+			}
+		}
+	}
+}
+
+func TestEncodeIsCompact(t *testing.T) {
+	values := make([]float64, 3600)
+	for i := range values {
+		values[i] = float64(i)
+	}
+	data, err := Encode(values, 1, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error. %s", err)
+	}
+	// one byte per value plus the header
+	if len(data) > len(values)+8 {
+		t.Errorf("Encoded stream is too large. Found(%d bytes)", len(data))
+	}
+}
+
+func TestEncodeInvalid(t *testing.T) {
+	if _, err := Encode([]float64{1, 2, 3}, 2, 0); err == nil {
+		t.Errorf("Expected an error for values that don't fit the dims")
+	}
+	if _, err := Encode([]float64{1}, 1, 12); err == nil {
+		t.Errorf("Expected an error for an out of range precision")
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	valid, _ := Encode([]float64{1, 2, 3}, 1, 0)
+	testCases := [][]byte{
+		nil,
+		{2, 0, 1, 0},
+		{version, 0, 0, 0},
+		{version, 0, 1, 200},
+		valid[:len(valid)-1],
+		append(append([]byte{}, valid...), 0),
+	}
+	for _, tc := range testCases {
+		if _, _, err := Decode(tc); err != ErrInvalid {
+			t.Errorf("Decode(%v) expected ErrInvalid. Found(%v)", tc, err)
+		}
+	}
+}
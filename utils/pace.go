@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// TimeBasis selects the activity time used to compute a pace or a speed
+type TimeBasis string
+
+const (
+	// ElapsedTime is the time from start to finish, the official race time
+	ElapsedTime TimeBasis = "elapsed"
+	// MovingTime leaves out the time stopped
+	MovingTime TimeBasis = "moving"
+)
+
+// ParseTimeBasis returns the time basis named elapsed or moving
+func ParseTimeBasis(name string) (TimeBasis, error) {
+	switch b := TimeBasis(name); b {
+	case ElapsedTime, MovingTime:
+		return b, nil
+	}
+	return "", fmt.Errorf("invalid time basis %q, expected %s or %s", name, ElapsedTime, MovingTime)
+}
+
+// Seconds returns the elapsed or the moving time
+func (b TimeBasis) Seconds(elapsedTime, movingTime uint32) uint32 {
+	if b == MovingTime {
+		return movingTime
+	}
+	return elapsedTime
+}
+
+// paceSports are the strava sport types measured by pace instead of speed
+var paceSports = []string{"Run", "TrailRun", "VirtualRun", "Walk", "Hike"}
+
+// UsesPace returns true when a sport is measured by pace, like running,
+// and false when it is measured by speed, like cycling.
+func UsesPace(sportType string) bool {
+	return sportType == "" || slices.Contains(paceSports, sportType)
+}
+
+// PaceSeconds returns the seconds per distance unit, or 0 without distance
+func (u Units) PaceSeconds(meters float64, seconds uint32) float64 {
+	if meters <= 0 {
+		return 0
+	}
+	return float64(seconds) / u.DistanceValue(meters)
+}
+
+// Pace returns the pace as minutes per distance unit: MM:SS /mi
+func (u Units) Pace(meters float64, seconds uint32) string {
+	// round the total to whole seconds so 5:59.6 becomes 6:00, not 5:60
+	pace := int(math.Round(u.PaceSeconds(meters, seconds)))
+	return fmt.Sprintf("%d:%02d /%s", pace/60, pace%60, u.Name)
+}
+
+// SpeedName returns the short name of the speed unit, e.g. mph
+func (u Units) SpeedName() string {
+	if u.IsMetric() {
+		return "kph"
+	}
+	return "mph"
+}
+
+// SpeedValue returns the distance units per hour, or 0 without time
+func (u Units) SpeedValue(meters float64, seconds uint32) float64 {
+	if seconds == 0 {
+		return 0
+	}
+	return u.DistanceValue(meters) / (float64(seconds) / 3600)
+}
+
+// Speed returns the speed formatted as 99.9 mph
+func (u Units) Speed(meters float64, seconds uint32) string {
+	return fmt.Sprintf("%0.1f %s", u.SpeedValue(meters, seconds), u.SpeedName())
+}
+
+// PaceOrSpeed returns the pace for sports measured by pace and the speed
+// for the others
+func (u Units) PaceOrSpeed(sportType string, meters float64, seconds uint32) string {
+	if UsesPace(sportType) {
+		return u.Pace(meters, seconds)
+	}
+	return u.Speed(meters, seconds)
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPaceRaceResults(t *testing.T) {
+	testCases := []struct {
+		name     string
+		meters   float64
+		seconds  uint32
+		imperial string
+		metric   string
+	}{
+		// Berlin 2022, 2:01:09
+		{"marathon world record", 42195, 7269, "4:37 /mi", "2:52 /km"},
+		// Valencia 2021, 57:31
+		{"half marathon world record", 21097.5, 3451, "4:23 /mi", "2:44 /km"},
+		// Monaco 2020, 12:35
+		{"5K world record", 5000, 755, "4:03 /mi", "2:31 /km"},
+		// Rome 1999, 3:43.13
+		{"mile world record", 1609.344, 223, "3:43 /mi", "2:19 /km"},
+		{"10 minute mile", 1609.344, 600, "10:00 /mi", "6:13 /km"},
+		{"no distance", 0, 600, "0:00 /mi", "0:00 /km"},
+	}
+	for _, tc := range testCases {
+		if res := Imperial.Pace(tc.meters, tc.seconds); res != tc.imperial {
+			t.Errorf("%s: incorrect imperial pace. Found(%s), Expected(%s)", tc.name, res, tc.imperial)
+		}
+		if res := Metric.Pace(tc.meters, tc.seconds); res != tc.metric {
+			t.Errorf("%s: incorrect metric pace. Found(%s), Expected(%s)", tc.name, res, tc.metric)
+		}
+	}
+}
+
+func TestPaceProperties(t *testing.T) {
+	distances := []float64{400, 1609.344, 5000, 8046.72, 10000, 21097.5, 42195, 80467}
+	for _, meters := range distances {
+		var prev float64
+		for seconds := uint32(60); seconds < 36000; seconds += 37 {
+			for _, u := range []Units{Imperial, Metric} {
+				pace := u.PaceSeconds(meters, seconds)
+				// pace times distance is the time
+				if d := math.Abs(pace*u.DistanceValue(meters) - float64(seconds)); d > 1e-6 {
+					t.Fatalf("%g m in %d s: pace does not add up to the time. Off by %g s", meters, seconds, d)
+				}
+				// pace times speed is an hour
+				if d := math.Abs(pace*u.SpeedValue(meters, seconds) - 3600); d > 1e-6 {
+					t.Fatalf("%g m in %d s: pace and speed disagree. Off by %g", meters, seconds, d)
+				}
+				// formatted seconds never round up to 60
+				s := u.Pace(meters, seconds)
+				var m, sec int
+				if _, err := fmt.Sscanf(s, "%d:%d", &m, &sec); err != nil || sec > 59 {
+					t.Fatalf("%g m in %d s: invalid pace %s", meters, seconds, s)
+				}
+			}
+			// a longer time is a slower pace
+			pace := Imperial.PaceSeconds(meters, seconds)
+			if pace <= prev {
+				t.Fatalf("%g m in %d s: pace is not slower than a shorter time", meters, seconds)
+			}
+			prev = pace
+			// a mile is longer than a kilometer
+			if Imperial.PaceSeconds(meters, seconds) <= Metric.PaceSeconds(meters, seconds) {
+				t.Fatalf("%g m in %d s: pace per mile is not slower than pace per km", meters, seconds)
+			}
+		}
+	}
+}
+
+func TestSpeed(t *testing.T) {
+	testCases := []struct {
+		name     string
+		meters   float64
+		seconds  uint32
+		imperial string
+		metric   string
+	}{
+		{"40 km time trial in an hour", 40000, 3600, "24.9 mph", "40.0 kph"},
+		{"century ride", 160934.4, 18000, "20.0 mph", "32.2 kph"},
+		{"no time", 1000, 0, "0.0 mph", "0.0 kph"},
+	}
+	for _, tc := range testCases {
+		if res := Imperial.Speed(tc.meters, tc.seconds); res != tc.imperial {
+			t.Errorf("%s: incorrect imperial speed. Found(%s), Expected(%s)", tc.name, res, tc.imperial)
+		}
+		if res := Metric.Speed(tc.meters, tc.seconds); res != tc.metric {
+			t.Errorf("%s: incorrect metric speed. Found(%s), Expected(%s)", tc.name, res, tc.metric)
+		}
+	}
+}
+
+func TestPaceOrSpeed(t *testing.T) {
+	if res := Imperial.PaceOrSpeed("TrailRun", 5000, 1500); !strings.HasSuffix(res, "/mi") {
+		t.Errorf("Expected a pace for a trail run. Found(%s)", res)
+	}
+	if res := Imperial.PaceOrSpeed("Ride", 40000, 3600); !strings.HasSuffix(res, "mph") {
+		t.Errorf("Expected a speed for a ride. Found(%s)", res)
+	}
+}
+
+func TestTimeBasis(t *testing.T) {
+	if s := ElapsedTime.Seconds(1230, 1200); s != 1230 {
+		t.Errorf("Incorrect elapsed time. Found(%d), Expected(%d)", s, 1230)
+	}
+	if s := MovingTime.Seconds(1230, 1200); s != 1200 {
+		t.Errorf("Incorrect moving time. Found(%d), Expected(%d)", s, 1200)
+	}
+	if _, err := ParseTimeBasis("total"); err == nil {
+		t.Errorf("Expected an error for an invalid time basis")
+	}
+}
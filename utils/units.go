@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Units is a system of units used to display activities
+type Units struct {
+	// Name is the short name of the distance unit, e.g. mi
+	Name string
+	// meters per distance unit
+	distance float64
+	// meters per elevation unit
+	elevation     float64
+	elevationName string
+}
+
+var (
+	Imperial = Units{Name: "mi", distance: 1609.344, elevation: 0.3048, elevationName: "ft"}
+	Metric   = Units{Name: "km", distance: 1000, elevation: 1, elevationName: "m"}
+)
+
+// ParseUnits returns the units named km, metric, mi or imperial
+func ParseUnits(name string) (Units, error) {
+	switch strings.ToLower(name) {
+	case "mi", "imperial":
+		return Imperial, nil
+	case "km", "metric":
+		return Metric, nil
+	}
+	return Units{}, fmt.Errorf("invalid units %q, expected km or mi", name)
+}
+
+// IsMetric returns true for kilometers and meters
+func (u Units) IsMetric() bool {
+	return u == Metric
+}
+
+// DistanceMeters returns the meters of one distance unit
+func (u Units) DistanceMeters() float64 {
+	return u.distance
+}
+
+// DistanceValue converts meters to the distance unit
+func (u Units) DistanceValue(meters float64) float64 {
+	return meters / u.distance
+}
+
+// ElevationValue converts meters to the elevation unit
+func (u Units) ElevationValue(meters float64) float64 {
+	return meters / u.elevation
+}
+
+// ElevationName returns the short name of the elevation unit, e.g. ft
+func (u Units) ElevationName() string {
+	return u.elevationName
+}
+
+// Distance returns a distance formatted as 99.99 mi
+func (u Units) Distance(meters float64) string {
+	return fmt.Sprintf("%0.2f %s", u.DistanceValue(meters), u.Name)
+}
+
+// ElevationChange returns a signed elevation change formatted as +99 ft
+func (u Units) ElevationChange(meters float64) string {
+	return fmt.Sprintf("%+.0f %s", u.ElevationValue(meters), u.elevationName)
+}